@@ -0,0 +1,77 @@
+package filepicker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// targets returns the paths a file operation applies to: the entries marked
+// in MultiSelect mode, or the entry under the cursor if none are marked.
+func (m Model) targets() []string {
+	if paths := m.SelectedFiles(); len(paths) > 0 {
+		return paths
+	}
+	if len(m.files) == 0 {
+		return nil
+	}
+	return []string{m.join(m.files[m.selected].Name())}
+}
+
+// sampleSize is how many names sampleNames lists.
+const sampleSize = 3
+
+// sampleNames returns the base names of the first few paths, followed by an
+// ellipsis if there are more.
+func sampleNames(paths []string) string {
+	n := len(paths)
+	if n > sampleSize {
+		n = sampleSize
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = filepath.Base(paths[i])
+	}
+	sample := strings.Join(names, ", ")
+	if len(paths) > n {
+		sample += ", …"
+	}
+	return sample
+}
+
+// clearSelection unmarks all entries, such as after an operation on them.
+func (m *Model) clearSelection() {
+	m.selectedFiles = map[string]struct{}{}
+	m.selectionOrder = nil
+}
+
+// batchError holds the errors of the entries an operation failed on, while it
+// went on with the others. format is Messages.BatchFailed.
+type batchError struct {
+	format string
+	errs   []error
+}
+
+func (e batchError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf(e.format, len(e.errs), strings.Join(msgs, "; "))
+}
+
+func (e batchError) Unwrap() []error {
+	return e.errs
+}
+
+// joinErrors returns nil if errs is empty, the only error if there is one and
+// a batchError listing all of them in the words of messages otherwise.
+func joinErrors(errs []error, messages Messages) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return batchError{format: messages.BatchFailed, errs: errs}
+}
@@ -0,0 +1,35 @@
+package filepicker
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Clipboard writes text to a clipboard.
+type Clipboard interface {
+	WriteText(text string) error
+}
+
+// SystemClipboard is the clipboard used by CopyPathToClipboard. It defaults
+// to the clipboard utility of the platform and can be replaced, e.g. with a
+// stub in tests.
+var SystemClipboard Clipboard = commandClipboard{}
+
+// CopyPathToClipboard copies path to the SystemClipboard.
+func CopyPathToClipboard(path string) error {
+	return SystemClipboard.WriteText(path)
+}
+
+// commandClipboard writes to the system clipboard by piping the text into
+// the clipboard utility of the platform.
+type commandClipboard struct{}
+
+func (commandClipboard) WriteText(text string) error {
+	name, args, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
@@ -0,0 +1,8 @@
+//go:build darwin
+// +build darwin
+
+package filepicker
+
+func clipboardCommand() (string, []string, error) {
+	return "pbcopy", nil, nil
+}
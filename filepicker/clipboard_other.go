@@ -0,0 +1,26 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package filepicker
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// clipboardCommand prefers wl-copy on Wayland and falls back to xclip.
+func clipboardCommand() (string, []string, error) {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return "wl-copy", nil, nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return "xclip", []string{"-selection", "clipboard"}, nil
+	}
+	if _, err := exec.LookPath("wl-copy"); err == nil {
+		return "wl-copy", nil, nil
+	}
+	return "", nil, errors.New("no clipboard utility found, install xclip or wl-clipboard")
+}
@@ -0,0 +1,8 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+func clipboardCommand() (string, []string, error) {
+	return "clip", nil, nil
+}
@@ -0,0 +1,52 @@
+package filepicker
+
+import (
+	"os"
+	"reflect"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// noColor reports whether the NO_COLOR environment variable asks for output
+// without colors. See https://no-color.org.
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// DisableColors replaces the styles with variants of them that have no colors
+// and no text attributes like bold, keeping their layout.
+func (m *Model) DisableColors() {
+	m.Styles = plainStyles(m.Styles)
+	m.colorsDisabled = true
+}
+
+// plainStyles returns a copy of styles with the colors and text attributes
+// removed from every style.
+func plainStyles(styles Styles) Styles {
+	v := reflect.ValueOf(&styles).Elem()
+	styleType := reflect.TypeOf(lipgloss.Style{})
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Field(i); field.Type() == styleType {
+			field.Set(reflect.ValueOf(plainStyle(field.Interface().(lipgloss.Style))))
+		}
+	}
+	return styles
+}
+
+// plainStyle returns a copy of s without colors and text attributes. The copy
+// is needed because unsetting a rule changes the style it was copied from.
+func plainStyle(s lipgloss.Style) lipgloss.Style {
+	return s.Copy().
+		UnsetForeground().
+		UnsetBackground().
+		UnsetBorderForeground().
+		UnsetBorderBackground().
+		UnsetMarginBackground().
+		UnsetBold().
+		UnsetFaint().
+		UnsetItalic().
+		UnsetUnderline().
+		UnsetStrikethrough().
+		UnsetReverse().
+		UnsetBlink()
+}
@@ -0,0 +1,116 @@
+package filepicker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// complete returns the names of the entries that start with prefix, ignoring
+// case, and the longest prefix they all have in common, which is what Tab
+// completes prefix to. If no entry matches, prefix itself is returned.
+func complete(prefix string, entries []os.DirEntry) (string, []string) {
+	lower := strings.ToLower(prefix)
+	var candidates []string
+	for _, entry := range entries {
+		if strings.HasPrefix(strings.ToLower(entry.Name()), lower) {
+			candidates = append(candidates, entry.Name())
+		}
+	}
+	if len(candidates) == 0 {
+		return prefix, nil
+	}
+
+	completion := candidates[0]
+	for _, candidate := range candidates[1:] {
+		completion = commonPrefix(completion, candidate)
+	}
+	// Case folding can change the length of a name, so make sure the input
+	// never gets shorter.
+	if utf8.RuneCountInString(completion) < utf8.RuneCountInString(prefix) {
+		return prefix, candidates
+	}
+	return completion, candidates
+}
+
+// commonPrefix returns the longest prefix of a that b starts with, comparing
+// runes without case.
+func commonPrefix(a, b string) string {
+	for i, r := range a {
+		s, size := utf8.DecodeRuneInString(b)
+		if size == 0 || !strings.EqualFold(string(r), string(s)) {
+			return a[:i]
+		}
+		b = b[size:]
+	}
+	return a
+}
+
+// completeFilter completes the filter query to the longest common prefix of
+// the names that start with it. If the query already is the only match, Tab
+// accepts it and filtering ends.
+func (m *Model) completeFilter() {
+	completion, candidates := complete(m.filterValue, m.allFiles)
+	if len(candidates) == 1 && completion == m.filterValue {
+		m.filtering = false
+		return
+	}
+	m.filterValue = completion
+	m.applyFilter()
+}
+
+// completePath completes the last segment of the typed path to the longest
+// common prefix of the names of the directories that start with it. It
+// reports whether the path already names the only match, so Tab accepts it.
+func (m *Model) completePath() bool {
+	value := m.pathInput.Value()
+	i := strings.LastIndexAny(value, "/"+string(filepath.Separator))
+	dir, base := value[:i+1], value[i+1:]
+
+	entries, err := m.readDirNamed(dir)
+	if err != nil {
+		return false
+	}
+	var dirs []os.DirEntry
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry)
+		}
+	}
+
+	completion, candidates := complete(base, dirs)
+	if len(candidates) == 1 && completion == base {
+		return true
+	}
+	m.pathInput.SetValue(dir + completion)
+	m.pathInput.CursorEnd()
+	return false
+}
+
+// readDirNamed reads the directory dir as typed into the path input, relative
+// to the current directory.
+func (m Model) readDirNamed(dir string) ([]os.DirEntry, error) {
+	if m.FileSystem != nil {
+		if dir == "" {
+			dir = m.CurrentDirectory
+		} else if !strings.HasPrefix(dir, "/") {
+			dir = m.join(dir)
+		}
+		return fs.ReadDir(m.FileSystem, strings.TrimSuffix(dir, "/"))
+	}
+	switch {
+	case dir == "":
+		dir = m.CurrentDirectory
+	case strings.HasPrefix(dir, "~"):
+		expanded, err := ExpandPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		dir = expanded
+	case !filepath.IsAbs(dir):
+		dir = m.join(dir)
+	}
+	return os.ReadDir(dir)
+}
@@ -0,0 +1,54 @@
+package filepicker
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplete(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"Documents", "Downloads", "docs.txt", "Music", "notes"} {
+		fsys[name] = &fstest.MapFile{}
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		prefix     string
+		completion string
+		candidates []string
+	}{
+		{"", "", []string{"Documents", "Downloads", "Music", "docs.txt", "notes"}},
+		{"Do", "Do", []string{"Documents", "Downloads", "docs.txt"}},
+		{"Doc", "Doc", []string{"Documents", "docs.txt"}},
+		{"Docu", "Documents", []string{"Documents"}},
+		// Matching ignores case, and completes to the case of the entry.
+		{"dow", "Downloads", []string{"Downloads"}},
+		{"mu", "Music", []string{"Music"}},
+		{"Documents", "Documents", []string{"Documents"}},
+		{"x", "x", nil},
+	} {
+		completion, candidates := complete(tt.prefix, entries)
+		assert.Equal(t, tt.completion, completion, tt.prefix)
+		assert.Equal(t, tt.candidates, candidates, tt.prefix)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		a, b, want string
+	}{
+		{"Documents", "Downloads", "Do"},
+		{"Documents", "docs.txt", "Doc"},
+		{"abc", "abcdef", "abc"},
+		{"abcdef", "abc", "abc"},
+		{"abc", "xyz", ""},
+		{"Über", "übrig", "Üb"},
+	} {
+		assert.Equal(t, tt.want, commonPrefix(tt.a, tt.b), "%s and %s", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,41 @@
+package filepicker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDirCopiesReadOnlyTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directories have no permission bits on Windows")
+	}
+	src := makeTree(t, "a.txt", "ro/b.txt", "ro/deep/c.txt")
+	for _, dir := range []string{"ro/deep", "ro"} {
+		require.NoError(t, os.Chmod(filepath.Join(src, dir), 0o555))
+	}
+	dst := filepath.Join(t.TempDir(), "copy")
+	// Make the copies removable again for the cleanup of the temp dirs.
+	t.Cleanup(func() {
+		for _, root := range []string{src, dst} {
+			os.Chmod(filepath.Join(root, "ro"), 0o755)
+			os.Chmod(filepath.Join(root, "ro", "deep"), 0o755)
+		}
+	})
+
+	require.NoError(t, CopyDir(src, dst))
+	for _, name := range []string{"a.txt", "ro/b.txt", "ro/deep/c.txt"} {
+		content, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		assert.Equal(t, name, string(content))
+	}
+	for _, dir := range []string{"ro", "ro/deep"} {
+		info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(dir)))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o555), info.Mode().Perm(), dir)
+	}
+}
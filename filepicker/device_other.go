@@ -0,0 +1,12 @@
+//go:build !unix
+// +build !unix
+
+package filepicker
+
+import "fmt"
+
+// device returns an error, since files have no device numbers on this
+// platform.
+func device(path string) (uint64, error) {
+	return 0, fmt.Errorf("no device number for %s", path)
+}
@@ -0,0 +1,23 @@
+//go:build unix
+// +build unix
+
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// device returns the number of the device the file at path is on.
+func device(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("no device number for %s", path)
+	}
+	return uint64(stat.Dev), nil
+}
@@ -0,0 +1,145 @@
+package filepicker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxDirSizeDepth is how many levels below a directory are walked when
+// computing its size. Anything deeper is left out of the total.
+const maxDirSizeDepth = 16
+
+// dirSizePending marks a directory whose size is still being computed.
+const dirSizePending = -1
+
+type dirSizeMsg struct {
+	path string
+	size int64
+}
+
+// dirSizesCmd returns a command computing the size of every directory in the
+// listing that isn't known or being computed yet, or nil if there is none.
+func (m *Model) dirSizesCmd() tea.Cmd {
+	if !m.ComputeDirSizes {
+		return nil
+	}
+	if m.dirSizes == nil {
+		m.dirSizes = map[string]int64{}
+	}
+	var cmds []tea.Cmd
+	for _, f := range m.allFiles {
+		if !f.IsDir() {
+			continue
+		}
+		path := m.join(f.Name())
+		if _, ok := m.dirSizes[path]; ok {
+			continue
+		}
+		m.dirSizes[path] = dirSizePending
+		cmds = append(cmds, m.dirSize(path))
+	}
+	return tea.Batch(cmds...)
+}
+
+// dirSize returns a command that sums up the sizes of the files below the
+// directory at path, at most maxDirSizeDepth levels deep. Entries that can't
+// be read are skipped.
+func (m Model) dirSize(path string) tea.Cmd {
+	fsys := m.FileSystem
+	return func() tea.Msg {
+		var total int64
+		walk := func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				rel := strings.TrimPrefix(strings.TrimPrefix(p, path), string(filepath.Separator))
+				if rel != "" && strings.Count(filepath.ToSlash(rel), "/") >= maxDirSizeDepth-1 {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return nil
+		}
+		if fsys != nil {
+			fs.WalkDir(fsys, path, walk)
+		} else {
+			filepath.WalkDir(path, walk)
+		}
+		return dirSizeMsg{path: path, size: total}
+	}
+}
+
+// dirCountPending marks a directory whose entries are still being counted.
+const dirCountPending = -1
+
+type dirCountMsg struct {
+	path  string
+	count int
+}
+
+// dirCountsCmd returns a command counting the entries of every directory in
+// the visible window that isn't counted or being counted yet, or nil if there
+// is none.
+func (m *Model) dirCountsCmd() tea.Cmd {
+	if !m.ShowDirCounts || len(m.files) == 0 {
+		return nil
+	}
+	if m.dirCounts == nil {
+		m.dirCounts = map[string]int{}
+	}
+	last := m.max
+	if last >= len(m.files) {
+		last = len(m.files) - 1
+	}
+	var cmds []tea.Cmd
+	for i := m.min; i >= 0 && i <= last; i++ {
+		f := m.files[i]
+		if !f.IsDir() {
+			continue
+		}
+		path := m.join(f.Name())
+		if _, ok := m.dirCounts[path]; ok {
+			continue
+		}
+		m.dirCounts[path] = dirCountPending
+		cmds = append(cmds, m.dirCount(path))
+	}
+	return tea.Batch(cmds...)
+}
+
+// dirCount returns a command that counts the entries of the directory at
+// path, leaving out hidden ones unless ShowHidden is set. A directory that
+// can't be read counts as empty.
+func (m Model) dirCount(path string) tea.Cmd {
+	fsys, showHidden := m.FileSystem, m.ShowHidden
+	return func() tea.Msg {
+		var entries []fs.DirEntry
+		if fsys != nil {
+			entries, _ = fs.ReadDir(fsys, path)
+		} else {
+			entries, _ = os.ReadDir(path)
+		}
+		count := 0
+		for _, entry := range entries {
+			if !showHidden {
+				isHidden := strings.HasPrefix(entry.Name(), ".")
+				if fsys == nil {
+					isHidden, _ = IsHidden(filepath.Join(path, entry.Name()))
+				}
+				if isHidden {
+					continue
+				}
+			}
+			count++
+		}
+		return dirCountMsg{path: path, count: count}
+	}
+}
@@ -1,17 +1,26 @@
 package filepicker
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dustin/go-humanize"
+	"github.com/muesli/reflow/truncate"
 )
 
 var (
@@ -20,6 +29,10 @@ var (
 	subtle = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
 )
 
+// ErrEmptyDirectory is returned by SelectedInfo when there is no entry under
+// the cursor.
+var ErrEmptyDirectory = errors.New("directory is empty")
+
 // Return the next ID we should use on the Model.
 func nextID() int {
 	idMtx.Lock()
@@ -28,9 +41,33 @@ func nextID() int {
 	return lastID
 }
 
+// ResetIDs resets the ID counter, so the next model gets the ID 1 again. It is
+// meant for tests that need deterministic IDs.
+func ResetIDs() {
+	idMtx.Lock()
+	defer idMtx.Unlock()
+	lastID = 0
+}
+
 // New returns a new filepicker model with default styling and key bindings.
 func New() Model {
-	return Model{
+	return NewWithOptions()
+}
+
+// NewWithConfig returns a new filepicker model with a fixed height and width
+// that starts in path, expanded with ExpandPath if possible.
+func NewWithConfig(height, width int, path string) Model {
+	if expanded, err := ExpandPath(path); err == nil {
+		path = expanded
+	}
+	return NewWithOptions(WithHeight(height), WithWidth(width), WithPath(path))
+}
+
+// NewWithOptions returns a new filepicker model with default styling and key
+// bindings, configured by opts. The styles have no colors if the NO_COLOR
+// environment variable is set.
+func NewWithOptions(opts ...Option) Model {
+	m := Model{
 		id:               nextID(),
 		CurrentDirectory: ".",
 		Cursor:           ">>",
@@ -39,97 +76,231 @@ func New() Model {
 		ShowHidden:       false,
 		DirAllowed:       false,
 		FileAllowed:      true,
+		FollowSymlinks:   true,
+		ShowSize:         true,
+		SizeAlign:        lipgloss.Right,
+		TruncateNames:    true,
+		ShowPathBox:      true,
 		AutoHeight:       true,
+		MarginBottom:     defaultMarginBottom,
+		MinHeight:        1,
+		ConfirmThreshold: 1,
 		Height:           0,
 		max:              0,
 		min:              0,
 		selectedStack:    newStack(),
 		minStack:         newStack(),
 		maxStack:         newStack(),
+		selectedFiles:    map[string]struct{}{},
+		loading:          true,
+		spinner:          spinner.New(spinner.WithSpinner(spinner.MiniDot)),
 		KeyMap:           DefaultKeyMap,
 		Styles:           DefaultStyles,
+		Messages:         DefaultMessages,
 	}
-}
-
-func NewWithConfig(height, width int, path string) Model {
-	return Model{
-		id:               nextID(),
-		CurrentDirectory: path,
-		PathUI:           path,
-		Cursor:           ">>",
-		AllowedTypes:     []string{},
-		selected:         0,
-		ShowHidden:       false,
-		DirAllowed:       false,
-		FileAllowed:      true,
-		AutoHeight:       false,
-		Height:           height,
-		Width:            width,
-		max:              0,
-		min:              0,
-		selectedStack:    newStack(),
-		minStack:         newStack(),
-		maxStack:         newStack(),
-		KeyMap:           DefaultKeyMap,
-		Styles:           DefaultStyles,
+	if noColor() {
+		m.DisableColors()
 	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
 }
 
+// PermissionFormat defines how the permission column is displayed.
+type PermissionFormat int
+
+const (
+	// PermSymbolic displays permissions like ls does, e.g. "drwxr-xr-x".
+	PermSymbolic PermissionFormat = iota
+	// PermOctal displays permissions as an octal number, e.g. "0755".
+	PermOctal
+	// PermNone leaves out the permission column.
+	PermNone
+)
+
+// bookmarkMode tells whether a bookmark key was pressed and the picker is
+// waiting for the letter of the bookmark.
+type bookmarkMode int
+
+const (
+	noBookmark bookmarkMode = iota
+	settingBookmark
+	jumpingToBookmark
+)
+
+// confirmAction tells which destructive action the user is asked to confirm.
+type confirmAction int
+
+const (
+	noConfirm confirmAction = iota
+	confirmDelete
+	confirmMove
+)
+
 type errorMsg struct {
 	err error
 }
 
-type readDirMsg []os.DirEntry
+// readDirMsg holds the entries of the current directory, read by the read
+// with the given generation.
+type readDirMsg struct {
+	gen     int
+	entries []os.DirEntry
+}
+
+// readDirErrorMsg is sent instead of a readDirMsg when the current directory
+// can't be read.
+type readDirErrorMsg struct {
+	gen int
+	err error
+}
+
+// nameScrollMsg scrolls the name of the selected row of the picker with the
+// given id.
+type nameScrollMsg struct {
+	id int
+}
 
 const (
-	marginBottom  = 5
-	fileSizeWidth = 8
-	paddingLeft   = 2
+	defaultMarginBottom = 5
+	fileSizeWidth       = 8
+	modTimeWidth        = 14
+	pathBoxWidth        = 50
+	paddingLeft         = 2
+
+	// The scrollbar is a single column with a space in front of it.
+	scrollbarWidth = 2
+
+	// Two clicks on the same entry within this interval count as a double click.
+	doubleClickInterval = 500 * time.Millisecond
+
+	// A name that is too long for the selected row scrolls by one rune every
+	// nameScrollInterval, and rests for nameScrollPause ticks at either end.
+	nameScrollInterval = 300 * time.Millisecond
+	nameScrollPause    = 3
+
+	ellipsis = "…"
 )
 
 // KeyMap defines key bindings for each user action.
 type KeyMap struct {
-	GoToTop  key.Binding
-	GoToLast key.Binding
-	Down     key.Binding
-	Up       key.Binding
-	PageUp   key.Binding
-	PageDown key.Binding
-	Back     key.Binding
-	Open     key.Binding
-	Select   key.Binding
-	Quit     key.Binding
+	GoToTop          key.Binding
+	GoToLast         key.Binding
+	Down             key.Binding
+	Up               key.Binding
+	PageUp           key.Binding
+	PageDown         key.Binding
+	Back             key.Binding
+	BackToRoot       key.Binding
+	Open             key.Binding
+	Select           key.Binding
+	Filter           key.Binding
+	Toggle           key.Binding
+	ConfirmSelection key.Binding
+	SelectAll        key.Binding
+	DeselectAll      key.Binding
+	InvertSelection  key.Binding
+	Sort             key.Binding
+	ToggleHidden     key.Binding
+	SelectMode       key.Binding
+	Reload           key.Binding
+	Rename           key.Binding
+	Delete           key.Binding
+	MkDir            key.Binding
+	EnterPath        key.Binding
+	SetBookmark      key.Binding
+	JumpBookmark     key.Binding
+	JumpLetter       key.Binding
+	CopyPath         key.Binding
+	Yank             key.Binding
+	Cut              key.Binding
+	Paste            key.Binding
+	OpenExternal     key.Binding
+	Inspect          key.Binding
+	Undo             key.Binding
+	Help             key.Binding
+	Quit             key.Binding
 }
 
 // DefaultKeyMap defines the default keybindings.
 var DefaultKeyMap = KeyMap{
-	GoToTop:  key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "first")),
-	GoToLast: key.NewBinding(key.WithKeys("G"), key.WithHelp("G", "last")),
-	Down:     key.NewBinding(key.WithKeys("j", "down", "ctrl+n"), key.WithHelp("j", "down")),
-	Up:       key.NewBinding(key.WithKeys("k", "up", "ctrl+p"), key.WithHelp("k", "up")),
-	PageUp:   key.NewBinding(key.WithKeys("K", "pgup"), key.WithHelp("pgup", "page up")),
-	PageDown: key.NewBinding(key.WithKeys("J", "pgdown"), key.WithHelp("pgdown", "page down")),
-	Back:     key.NewBinding(key.WithKeys("h", "backspace", "left", "esc"), key.WithHelp("h", "back")),
-	Open:     key.NewBinding(key.WithKeys("l", "right", "enter"), key.WithHelp("l", "open")),
-	Select:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
-	Quit:     key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+	GoToTop:          key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "first")),
+	GoToLast:         key.NewBinding(key.WithKeys("G"), key.WithHelp("G", "last")),
+	Down:             key.NewBinding(key.WithKeys("j", "down", "ctrl+n"), key.WithHelp("j", "down")),
+	Up:               key.NewBinding(key.WithKeys("k", "up", "ctrl+p"), key.WithHelp("k", "up")),
+	PageUp:           key.NewBinding(key.WithKeys("K", "pgup"), key.WithHelp("pgup", "page up")),
+	PageDown:         key.NewBinding(key.WithKeys("J", "pgdown"), key.WithHelp("pgdown", "page down")),
+	Back:             key.NewBinding(key.WithKeys("h", "backspace", "left", "esc"), key.WithHelp("h", "back")),
+	BackToRoot:       key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "root")),
+	Open:             key.NewBinding(key.WithKeys("l", "right", "enter"), key.WithHelp("l", "open")),
+	Select:           key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select")),
+	Filter:           key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter")),
+	Toggle:           key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle")),
+	ConfirmSelection: key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "done")),
+	SelectAll:        key.NewBinding(key.WithKeys("ctrl+a"), key.WithHelp("ctrl+a", "all")),
+	DeselectAll:      key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "none")),
+	InvertSelection:  key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "invert")),
+	Sort:             key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort")),
+	ToggleHidden:     key.NewBinding(key.WithKeys("."), key.WithHelp(".", "hidden")),
+	SelectMode:       key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "select")),
+	Reload:           key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "reload")),
+	Rename:           key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename")),
+	Delete:           key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+	MkDir:            key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new dir")),
+	EnterPath:        key.NewBinding(key.WithKeys(":"), key.WithHelp(":", "go to")),
+	SetBookmark:      key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "bookmark")),
+	JumpBookmark:     key.NewBinding(key.WithKeys("'"), key.WithHelp("'", "jump")),
+	JumpLetter:       key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "find letter")),
+	CopyPath:         key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "copy path")),
+	Yank:             key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yank")),
+	Cut:              key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "cut")),
+	Paste:            key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "paste")),
+	OpenExternal:     key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open with app")),
+	Inspect:          key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "info")),
+	Undo:             key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "undo")),
+	Help:             key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
+	Quit:             key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
 }
 
 // Styles defines the possible customizations for styles in the file picker.
 type Styles struct {
-	DisabledCursor   lipgloss.Style
-	Cursor           lipgloss.Style
-	Symlink          lipgloss.Style
-	Directory        lipgloss.Style
-	File             lipgloss.Style
-	DisabledFile     lipgloss.Style
-	Permission       lipgloss.Style
-	Selected         lipgloss.Style
-	DisabledSelected lipgloss.Style
-	FileSize         lipgloss.Style
-	EmptyDirectory   lipgloss.Style
-	MainPath         lipgloss.Style
-	MainBox          lipgloss.Style
+	DisabledCursor      lipgloss.Style
+	Cursor              lipgloss.Style
+	Symlink             lipgloss.Style
+	Directory           lipgloss.Style
+	File                lipgloss.Style
+	DisabledFile        lipgloss.Style
+	Permission          lipgloss.Style
+	Selected            lipgloss.Style
+	DisabledSelected    lipgloss.Style
+	FileSize            lipgloss.Style
+	EmptyDirectory      lipgloss.Style
+	ModTime             lipgloss.Style
+	MainPath            lipgloss.Style
+	MainBox             lipgloss.Style
+	Filter              lipgloss.Style
+	Marked              lipgloss.Style
+	Confirm             lipgloss.Style
+	Bookmarks           lipgloss.Style
+	Help                lipgloss.Style
+	Breadcrumb          lipgloss.Style
+	BreadcrumbSeparator lipgloss.Style
+	ScrollIndicator     lipgloss.Style
+	Scrollbar           lipgloss.Style
+	Error               lipgloss.Style
+	SelectableDirectory lipgloss.Style
+	Indicator           lipgloss.Style
+	GroupHeader         lipgloss.Style
+	Loading             lipgloss.Style
+	Badge               lipgloss.Style
+	Inspect             lipgloss.Style
+	InspectLabel        lipgloss.Style
+	GitModified         lipgloss.Style
+	GitUntracked        lipgloss.Style
+	GitStaged           lipgloss.Style
+	DirCount            lipgloss.Style
+	Notice              lipgloss.Style
 }
 
 // DefaultStyles defines the default styling for the file picker.
@@ -144,6 +315,7 @@ var DefaultStyles = Styles{
 	Permission:       lipgloss.NewStyle().Foreground(lipgloss.Color("244")),
 	Selected:         lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true),
 	FileSize:         lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Width(fileSizeWidth).Align(lipgloss.Right),
+	ModTime:          lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Width(modTimeWidth).Align(lipgloss.Right),
 	EmptyDirectory:   lipgloss.NewStyle().Foreground(lipgloss.Color("240")).PaddingLeft(paddingLeft).SetString("Bummer. No Files Found."),
 	MainPath:         lipgloss.NewStyle().Foreground(lipgloss.Color("240")).PaddingLeft(paddingLeft),
 	MainBox: lipgloss.NewStyle().
@@ -153,10 +325,35 @@ var DefaultStyles = Styles{
 		BorderLeft(true).
 		BorderRight(true).
 		BorderBottom(true),
+	Filter:              lipgloss.NewStyle().Foreground(lipgloss.Color("212")).PaddingLeft(paddingLeft),
+	Marked:              lipgloss.NewStyle().Foreground(lipgloss.Color("78")).Bold(true),
+	Confirm:             lipgloss.NewStyle().Foreground(lipgloss.Color("203")).Bold(true).PaddingLeft(paddingLeft),
+	Bookmarks:           lipgloss.NewStyle().Foreground(lipgloss.Color("240")).PaddingLeft(paddingLeft),
+	Help:                lipgloss.NewStyle().Foreground(lipgloss.Color("241")).PaddingLeft(paddingLeft),
+	Breadcrumb:          lipgloss.NewStyle().Foreground(lipgloss.Color("99")),
+	BreadcrumbSeparator: lipgloss.NewStyle().Foreground(lipgloss.Color("240")).SetString(" › "),
+	ScrollIndicator:     lipgloss.NewStyle().Foreground(lipgloss.Color("240")).PaddingLeft(paddingLeft),
+	Scrollbar:           lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+	Error:               lipgloss.NewStyle().Foreground(lipgloss.Color("203")).PaddingLeft(paddingLeft),
+	SelectableDirectory: lipgloss.NewStyle().Foreground(lipgloss.Color("78")).SetString(" ◆"),
+	Indicator:           lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+	GroupHeader:         lipgloss.NewStyle().Foreground(lipgloss.Color("99")).Bold(true).PaddingLeft(paddingLeft),
+	Loading:             lipgloss.NewStyle().Foreground(lipgloss.Color("240")).PaddingLeft(paddingLeft),
+	Badge:               lipgloss.NewStyle().Foreground(lipgloss.Color("78")),
+	Inspect:             lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#874BFD")).Padding(0, 1),
+	InspectLabel:        lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+	GitModified:         lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	GitUntracked:        lipgloss.NewStyle().Foreground(lipgloss.Color("203")),
+	GitStaged:           lipgloss.NewStyle().Foreground(lipgloss.Color("78")),
+	DirCount:            lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
+	Notice:              lipgloss.NewStyle().Foreground(lipgloss.Color("78")).PaddingLeft(paddingLeft),
 }
 
 // Model represents a file picker.
 type Model struct {
+	// id tells models apart, the same way the bubbles components do, so that
+	// the ticks scrolling the selected name only reach the model that
+	// scheduled them.
 	id int
 
 	// Path is the path which the user has selected with the file picker.
@@ -167,20 +364,233 @@ type Model struct {
 	// CurrentDirectory is the directory that the user is currently in.
 	CurrentDirectory string
 
-	// AllowedTypes specifies which file types the user may select.
-	// If empty the user may select any file.
-	AllowedTypes []string
+	// MinFileSize and MaxFileSize are the sizes in bytes below and above
+	// which files are disabled and can't be selected, e.g. a MinFileSize of 1
+	// only allows non-empty files. Zero means there is no limit.
+	MinFileSize int64
+	MaxFileSize int64
+
+	// ModeFilter, if set, disables files whose mode has none of its bits set,
+	// e.g. 0o111 only allows executables.
+	ModeFilter os.FileMode
+
+	// FileSystem, if set, is browsed instead of the local disk, e.g. an
+	// embed.FS or a zip.Reader. CurrentDirectory is then a slash-separated
+	// path within it, with "." as its root. Renaming and deleting entries are
+	// only supported on the local disk.
+	FileSystem fs.FS
+
+	// AllowedTypes specifies which file types the user may select, either as
+	// suffixes like ".png" or as glob patterns like "data-??.json".
+	// If empty the user may select any file. Matching ignores case unless
+	// CaseSensitiveTypes is set.
+	AllowedTypes       []string
+	CaseSensitiveTypes bool
+
+	// HiddenExtensions specifies files that are left out of the listing, even
+	// when ShowHidden is set. They are matched the same way as AllowedTypes.
+	HiddenExtensions []string
 
 	KeyMap      KeyMap
 	files       []os.DirEntry
+	allFiles    []os.DirEntry
 	ShowHidden  bool
 	DirAllowed  bool
 	FileAllowed bool
 
+	// PermissionFormat is how the permission column is displayed, if at all.
+	PermissionFormat PermissionFormat
+
+	// ShowSize shows the size column, aligned within it by SizeAlign.
+	// Directories show "-" unless ComputeDirSizes is set.
+	ShowSize  bool
+	SizeAlign lipgloss.Position
+
+	// Recursive lists all files below the current directory, at most
+	// RecursiveDepth levels deep, by their path relative to it. Zero means 8
+	// levels. Only the first 10000 files are listed.
+	Recursive      bool
+	RecursiveDepth int
+	treeTruncated  bool
+
+	// ShowFullPaths shows the full path of each entry instead of its name.
+	ShowFullPaths bool
+
+	// Compact renders only the cursor, the icon and the name of each entry,
+	// leaving out the permission, size and modification time columns.
+	Compact bool
+
+	// GroupByType lists directories, images, documents and other files in
+	// sections under a header each. The headers are rendered on top of the
+	// Height entries in view and the cursor never lands on them.
+	GroupByType bool
+
+	// ClassifyEntries appends an indicator of the type to each name like
+	// ls -F does: "/" for directories, "@" for symlinks and "*" for
+	// executables.
+	ClassifyEntries bool
+
+	// UseBinaryUnits shows sizes in IEC units like "1.4 MiB" instead of SI
+	// units like "1.5 MB".
+	UseBinaryUnits bool
+
+	// ShowModTime adds a column with the modification time of each entry.
+	// TimeFormat is the time.Format layout used for it, or relative times
+	// like "3 days ago" when empty.
+	ShowModTime bool
+	TimeFormat  string
+
+	// ComputeDirSizes shows the total size of the files in each directory
+	// instead of the size of the directory entry itself. The sizes are
+	// computed in the background and cached in dirSizes by path.
+	ComputeDirSizes bool
+	dirSizes        map[string]int64
+
+	// ShowDirCounts shows the number of entries of each directory after its
+	// name. Only the directories in view are counted, in the background, and
+	// the counts are cached in dirCounts by path.
+	ShowDirCounts bool
+	dirCounts     map[string]int
+
+	// ShowGitStatus colors the entries that git reports as modified,
+	// untracked or staged when the current directory is in a repository.
+	// gitStatuses holds the status of the entries of gitStatusDir by name.
+	ShowGitStatus bool
+	gitStatuses   map[string]gitStatus
+	gitStatusDir  string
+
+	// SelectOnEnter makes enter select files as well as open directories, as
+	// it did before the Select key got a binding of its own.
+	SelectOnEnter bool
+
+	// FollowSymlinks makes symlinks to directories behave like directories.
+	// When false, such symlinks can't be opened and are selected like files.
+	FollowSymlinks bool
+
+	// SortMode is the order in which entries are listed. SortReverse flips
+	// it, and MixDirsAndFiles stops directories from being grouped first.
+	SortMode        SortMode
+	SortReverse     bool
+	MixDirsAndFiles bool
+
+	// SortFunc, if set, orders the entries instead of SortMode, SortReverse
+	// and MixDirsAndFiles. Wrap it with DirsFirst to keep directories on top.
+	SortFunc func(a, b os.DirEntry) bool
+
+	// filtering is true while the user is typing a filter query. The query in
+	// filterValue narrows allFiles down to files and stays applied after the
+	// input is closed, until it is cleared with esc.
+	filtering   bool
+	filterValue string
+
+	// renaming is true while the user edits the name of the entry under the
+	// cursor in renameInput.
+	renaming    bool
+	renameInput textinput.Model
+
+	// creatingDir is true while the user types the name of a new directory
+	// in mkdirInput.
+	creatingDir bool
+	mkdirInput  textinput.Model
+
+	// enteringPath is true while the user types a directory to go to in
+	// pathInput.
+	enteringPath bool
+	pathInput    textinput.Model
+
+	// confirming tells which action the user is asked to confirm: deleting
+	// the entries returned by targets, or moving the cut entries into the
+	// current directory. ConfirmThreshold is the number of entries from which
+	// on the user is asked, so with 2 single entries are deleted right away.
+	confirming       confirmAction
+	ConfirmThreshold int
+
+	// UseTrash makes the Delete key move entries to the trash with TrashFile,
+	// directories along with everything in them, instead of removing them for
+	// good. If there is no trash, they are removed anyway and a warning is
+	// shown. Entries that can't be moved to the trash otherwise are left alone.
+	UseTrash bool
+
+	// undoStack holds the renames and moves to the trash that the Undo key
+	// can revert, the last one on top. Permanent deletes can't be undone and
+	// aren't pushed.
+	undoStack []undoEntry
+
+	// yanked holds the paths put on the internal clipboard with the Yank or
+	// Cut key, to be copied, or moved if yankCut is true, into the current
+	// directory with the Paste key.
+	yanked  []string
+	yankCut bool
+
+	// inspecting is true while the details of the entry under the cursor are
+	// shown with the Inspect key. Any key closes them.
+	inspecting bool
+
+	// Bookmarks maps letters to directories the user can jump back to. They
+	// are set with the SetBookmark key and used with the JumpBookmark key,
+	// each followed by the letter. bookmarking tells which of the two is
+	// waiting for its letter.
+	Bookmarks   map[rune]string
+	bookmarking bookmarkMode
+
+	// jumpingToLetter is set after the JumpLetter key, while the picker waits
+	// for the letter to jump to. Unbound letters jump without it.
+	jumpingToLetter bool
+
+	// err is the error of the last failed file operation. It is shown until
+	// the next key press, like notice, which tells what was undone.
+	err    error
+	notice string
+
+	// loading is true from the moment the directory is read until its entries
+	// arrive. View shows a spinner meanwhile.
+	loading bool
+	spinner spinner.Model
+
+	// shownDirectory is the directory whose entries are listed. The picker
+	// returns to it if CurrentDirectory can't be read.
+	shownDirectory string
+
+	// readGen counts the directory reads. Each read carries the count at the
+	// time it started, so the results of a read that was overtaken by a newer
+	// one are dropped instead of replacing the newer listing.
+	readGen int
+
+	// onSelect, onError, onDirChange and onHighlight are the callbacks
+	// registered with OnSelect, OnError, OnDirChange and OnHighlight.
+	onSelect    func(path string)
+	onError     func(err error)
+	onDirChange func(dir string)
+	onHighlight func(path string)
+
 	FileSelected  string
 	selected      int
+	lastErr       error
 	selectedStack stack
 
+	// focusName is the name of the entry to put the cursor on once the
+	// directory being read arrives, if any.
+	focusName string
+
+	// StartOn is the name of the entry to put the cursor on when the initial
+	// directory is shown. started is set once that directory has arrived.
+	StartOn string
+	started bool
+
+	// RootDirectory is the directory BackToRoot returns to. It defaults to the
+	// initial directory. If RootDirectory is set or ConfineToRoot is, the user
+	// can't leave it, neither by going back nor through a symlink.
+	RootDirectory    string
+	ConfineToRoot    bool
+	initialDirectory string
+
+	// MultiSelect lets the user mark several entries with the Toggle key.
+	// selectedFiles holds the marked paths and selectionOrder remembers the
+	// order in which they were marked.
+	MultiSelect    bool
+	selectedFiles  map[string]struct{}
+	selectionOrder []string
+
 	min      int
 	max      int
 	maxStack stack
@@ -190,8 +600,88 @@ type Model struct {
 	AutoHeight bool
 	Width      int
 
-	Cursor string
-	Styles Styles
+	// MarginBottom is the number of terminal lines AutoHeight leaves free for
+	// whatever is rendered around the picker.
+	MarginBottom int
+
+	// MinHeight and MaxHeight bound the height AutoHeight picks. A MaxHeight
+	// of zero leaves it unbounded.
+	MinHeight int
+	MaxHeight int
+
+	// ShowIcons puts a Nerd Font glyph in front of each name, looked up by
+	// extension in Icons, or in DefaultIcons if Icons is nil.
+	ShowIcons bool
+	Icons     map[string]string
+
+	// ExtensionStyles styles the names of files by extension, like LS_COLORS
+	// does, instead of Styles.File. The keys are lower case extensions
+	// including the dot, such as ".png".
+	ExtensionStyles map[string]lipgloss.Style
+
+	// WatchDirectory re-reads the current directory whenever one of its
+	// entries changes on the local disk. Call Close once the picker is no
+	// longer used to stop watching.
+	WatchDirectory bool
+	watcher        *dirWatcher
+
+	// WrapNavigation moves the cursor from the last entry to the first one when
+	// going down, and from the first to the last when going up.
+	WrapNavigation bool
+
+	// TruncateNames cuts names that don't fit into Width and ends them with an
+	// ellipsis. The name of the selected row scrolls instead, so it can be read
+	// in full. nameScrolling is true while the ticks that scroll it run, which
+	// is only as long as the name doesn't fit.
+	TruncateNames bool
+	nameScroll    int
+	nameScrollRow int
+	nameScrolling bool
+
+	// BreadcrumbMode replaces the centered path box in the header with a
+	// breadcrumb of the current directory.
+	BreadcrumbMode bool
+
+	// ShowPathBox draws the path centered in the bordered MainBox. If false,
+	// the path is a plain line styled with MainPath, which leaves more rows
+	// for the list.
+	ShowPathBox bool
+
+	// EmptyMessage replaces Messages.EmptyDirectory, the text shown for a
+	// directory without entries.
+	EmptyMessage string
+
+	// ShowHelp renders a footer listing the key bindings. It is toggled with
+	// the Help key.
+	ShowHelp bool
+
+	// ShowScrollIndicator renders which entries are in view below the listing,
+	// e.g. "showing 12-21 of 87", when they don't all fit.
+	ShowScrollIndicator bool
+
+	// ShowScrollbar renders a scrollbar to the right of the listing whose thumb
+	// shows which part of the entries is in view.
+	ShowScrollbar bool
+
+	// OffsetY is the number of rows the parent renders above the file
+	// picker. It is needed to map mouse clicks onto entries.
+	OffsetY        int
+	lastClick      time.Time
+	lastClickIndex int
+
+	// Cursor marks the selected row, and DisabledCursorString the selected row
+	// if its entry can't be selected. DisabledCursorString defaults to Cursor.
+	// Without a cursor, the selected row is only told apart by its style.
+	Cursor               string
+	DisabledCursorString string
+	Styles               Styles
+
+	// Messages is the text shown to the user. See DefaultMessages.
+	Messages Messages
+
+	// colorsDisabled is set by DisableColors, for the colors that don't come
+	// from Styles.
+	colorsDisabled bool
 }
 
 type stack struct {
@@ -227,156 +717,568 @@ func (m Model) popView() (int, int, int) {
 	return m.selectedStack.Pop(), m.minStack.Pop(), m.maxStack.Pop()
 }
 
-func readDir(path string, showHidden bool) tea.Cmd {
+// readDir returns a command that lists the current directory using the
+// model's sorting and hidden file settings.
+func (m *Model) readDir() tea.Cmd {
+	m.readGen++
+	read := m.readDirCmd()
+	// Start the spinner of the loading indicator unless it is spinning already.
+	if !m.loading {
+		m.loading = true
+		return tea.Batch(read, m.spinner.Tick)
+	}
+	return read
+}
+
+// staleRead reports whether a read with the given generation was started
+// before the latest one, so its result is out of date. Reads started on a copy
+// of the model can be newer than readGen, which then catches up with them.
+func (m *Model) staleRead(gen int) bool {
+	if gen < m.readGen {
+		return true
+	}
+	m.readGen = gen
+	return false
+}
+
+// readDirCmd returns the command that reads the current directory.
+func (m Model) readDirCmd() tea.Cmd {
+	path, showHidden, fsys := m.CurrentDirectory, m.ShowHidden, m.FileSystem
+	mode, reverse, mixDirs, sortFunc := m.SortMode, m.SortReverse, m.MixDirsAndFiles, m.SortFunc
+	hiddenExtensions, groupByType := m.HiddenExtensions, m.GroupByType
+	recursive, depth := m.Recursive, m.RecursiveDepth
+	gen := m.readGen
+	if depth <= 0 {
+		depth = defaultRecursiveDepth
+	}
 	return func() tea.Msg {
-		dirEntries, err := os.ReadDir(path)
+		var dirEntries []os.DirEntry
+		var truncated bool
+		var err error
+		switch {
+		case recursive:
+			dirEntries, truncated, err = readTree(fsys, path, showHidden, depth)
+		case fsys != nil:
+			dirEntries, err = fs.ReadDir(fsys, path)
+		default:
+			dirEntries, err = os.ReadDir(path)
+		}
 		if err != nil {
-			return errorMsg{err}
+			return readDirErrorMsg{gen, err}
 		}
 
-		// sort directories alphabetically
-		sort.Slice(dirEntries, func(i, j int) bool {
-			if dirEntries[i].IsDir() == dirEntries[j].IsDir() {
-				return dirEntries[i].Name() < dirEntries[j].Name()
+		// The files of a Recursive listing are sent along with whether some
+		// were left out.
+		result := func(entries []os.DirEntry) tea.Msg {
+			if recursive {
+				return treeMsg{gen: gen, entries: entries, truncated: truncated}
 			}
-			return dirEntries[i].IsDir()
-		})
+			return readDirMsg{gen, entries}
+		}
+
+		if sortFunc != nil {
+			sort.SliceStable(dirEntries, func(i, j int) bool {
+				return sortFunc(dirEntries[i], dirEntries[j])
+			})
+		} else {
+			sortEntries(dirEntries, mode, reverse, mixDirs)
+		}
+		if groupByType {
+			groupEntries(dirEntries)
+		}
 
-		// if hidden files are allowed, return the dirEntries as is
-		if showHidden {
-			return readDirMsg(dirEntries)
+		// if hidden files are allowed and no extensions are hidden, return the dirEntries as is
+		if showHidden && len(hiddenExtensions) == 0 {
+			return result(dirEntries)
 		}
 		// otherwise, filter out hidden files
 		var sanitizedDirEntries []os.DirEntry
 		for _, dirEntry := range dirEntries {
-			isHidden, _ := IsHidden(dirEntry.Name())
+			// Hidden extensions are left out even if hidden files are shown.
+			if !dirEntry.IsDir() && m.matchesAny(dirEntry.Name(), hiddenExtensions) {
+				continue
+			}
+			if showHidden {
+				sanitizedDirEntries = append(sanitizedDirEntries, dirEntry)
+				continue
+			}
+			// File attributes are only available on the local disk.
+			isHidden := strings.HasPrefix(dirEntry.Name(), ".")
+			if fsys == nil {
+				isHidden, _ = IsHidden(filepath.Join(path, dirEntry.Name()))
+			}
 			if isHidden {
 				continue
 			}
 			sanitizedDirEntries = append(sanitizedDirEntries, dirEntry)
 		}
-		return readDirMsg(sanitizedDirEntries)
+		return result(sanitizedDirEntries)
 	}
 }
 
 // Init initializes the file picker model.
 func (m Model) Init() tea.Cmd {
-	return readDir(m.CurrentDirectory, m.ShowHidden)
+	// The model starts out loading, so the spinner has to be started here.
+	return tea.Batch(m.readDirCmd(), m.spinner.Tick)
+}
+
+// scrollName schedules the next step of scrolling the selected name.
+func (m Model) scrollName() tea.Cmd {
+	id := m.id
+	return tea.Tick(nameScrollInterval, func(time.Time) tea.Msg {
+		return nameScrollMsg{id: id}
+	})
+}
+
+// startNameScroll starts scrolling the selected name if it doesn't fit and
+// isn't scrolling yet.
+func (m *Model) startNameScroll() tea.Cmd {
+	if m.nameScrolling || !m.selectedNameOverflows() {
+		return nil
+	}
+	m.nameScrolling = true
+	return m.scrollName()
+}
+
+// selectedNameOverflows reports whether the label of the row under the cursor
+// is truncated to fit into Width.
+func (m Model) selectedNameOverflows() bool {
+	if !m.TruncateNames || m.Width <= 0 || len(m.files) == 0 {
+		return false
+	}
+	_, label, _, space := m.selectedLabel(m.entryRow(m.files[m.selected]), m.listWidth())
+	return lipgloss.Width(label) > space
 }
 
-// Update handles user interactions within the file picker model.
+// Update handles user interactions within the file picker model. Whatever
+// the message, it then starts scrolling the selected name if needed.
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	if scroll := m.startNameScroll(); scroll != nil {
+		cmd = tea.Batch(cmd, scroll)
+	}
+	return m, cmd
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
+	case errorMsg: // If msg is an errorMsg, keep the error around to show it in the view.
+		m.err = msg.err
+		m.focusName = ""
+		if m.onError != nil {
+			m.onError(msg.err)
+		}
+
+	case spinner.TickMsg: // If msg is a spinner.TickMsg, animate the loading indicator while loading.
+		if !m.loading {
+			break
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case readDirErrorMsg: // If msg is a readDirErrorMsg, show the error and go back to the directory that is still listed.
+		if m.staleRead(msg.gen) {
+			return m, nil
+		}
+		m.loading = false
+		m.err = msg.err
+		m.focusName = ""
+		if m.onError != nil {
+			m.onError(msg.err)
+		}
+		if m.shownDirectory != "" && m.CurrentDirectory != m.shownDirectory {
+			failed := m.CurrentDirectory
+			m.CurrentDirectory = m.shownDirectory
+			m.PathUI = m.CurrentDirectory
+			m.notifyDirChange(failed)
+			// Opening the directory saved the view of this one, which is
+			// still on screen.
+			if m.parentDirOf(failed) == m.CurrentDirectory && m.selectedStack.Length() > 0 {
+				m.selected, m.min, m.max = m.popView()
+			}
+			m.focusEntry(filepath.Base(failed))
+		}
+
+	case treeMsg: // If msg is a treeMsg, list the files like those of a readDirMsg.
+		if m.staleRead(msg.gen) {
+			return m, nil
+		}
+		m.treeTruncated = msg.truncated
+		return m.Update(readDirMsg{msg.gen, msg.entries})
+
 	case readDirMsg: // If msg in readDirMsg, update the files in the current directory.
-		m.files = msg
-		m.max = m.Height - 1
-	case tea.WindowSizeMsg: // If msg is a WindowSizeMsg, update the height of the file picker.
+		if m.staleRead(msg.gen) {
+			return m, nil
+		}
+		m.loading = false
+		m.shownDirectory = m.CurrentDirectory
+		m.allFiles = msg.entries
+		m.files = filterEntries(m.allFiles, m.filterValue)
+		if !m.started {
+			// This is the initial directory, so start on StartOn if present.
+			m.started = true
+			m.focusName = m.StartOn
+			m.initialDirectory = m.CurrentDirectory
+		}
+		if m.focusName != "" {
+			m.focusEntry(m.focusName)
+			m.focusName = ""
+		}
+		m.clampView()
+		cmds := tea.Batch(m.dirSizesCmd(), m.dirCountsCmd(), m.gitStatusCmd(), m.watch())
+		return m, cmds
+
+	case dirChangedMsg: // If msg is a dirChangedMsg, re-read the directory if it is still the current one.
+		if msg.dir != "" && filepath.Clean(msg.dir) != filepath.Clean(m.CurrentDirectory) {
+			return m, m.watcher.waitForChange()
+		}
+		if len(m.files) > 0 {
+			m.focusName = m.files[m.selected].Name()
+		}
+		return m, tea.Batch(m.readDir(), m.watcher.waitForChange())
+
+	case dirSizeMsg: // If msg is a dirSizeMsg, cache the size of the directory.
+		m.dirSizes[msg.path] = msg.size
+
+	case dirCountMsg: // If msg is a dirCountMsg, cache the number of entries of the directory.
+		if m.dirCounts != nil {
+			m.dirCounts[msg.path] = msg.count
+		}
+
+	case gitStatusMsg: // If msg is a gitStatusMsg, color the entries git reports.
+		m.gitStatuses, m.gitStatusDir = msg.statuses, msg.dir
+
+	case nameScrollMsg: // If msg is a nameScrollMsg, scroll the selected name one step.
+		if msg.id != m.id {
+			break
+		}
+		// The ticks stop once the selected name fits, and Update starts them
+		// again when one doesn't.
+		if !m.selectedNameOverflows() {
+			m.nameScrolling = false
+			m.nameScroll = 0
+			break
+		}
+		// Moving the cursor starts the new row from the beginning of its name.
+		if m.nameScrollRow != m.selected {
+			m.nameScrollRow = m.selected
+			m.nameScroll = 0
+		} else {
+			m.nameScroll++
+		}
+		return m, m.scrollName()
+
+	case tea.WindowSizeMsg: // If msg is a WindowSizeMsg, update the size of the file picker.
 		if m.AutoHeight {
-			m.Height = msg.Height - marginBottom
+			m.Height = msg.Height - m.MarginBottom
+			if m.MaxHeight > 0 && m.Height > m.MaxHeight {
+				m.Height = m.MaxHeight
+			}
+			if m.Height < m.MinHeight {
+				m.Height = m.MinHeight
+			}
+		}
+		// Keep the cursor in view, wherever the list was scrolled to.
+		m.clampView()
+		// The list takes the whole terminal width. headerView leaves room for
+		// the border of the MainBox itself.
+		m.Width = msg.Width
+
+	case tea.MouseMsg: // If msg is a MouseMsg, scroll with the wheel or pick the clicked entry.
+		highlighted := m.selected
+		switch msg.Type {
+		case tea.MouseWheelUp:
+			m.moveUp()
+		case tea.MouseWheelDown:
+			m.moveDown()
+		case tea.MouseLeft:
+			if len(m.files) == 0 {
+				break
+			}
+			// The parent renders OffsetY rows above the file picker, and the
+			// header takes up as many rows as it has line breaks, so the first
+			// row after that shows m.files[m.min]. Each following row shows
+			// the next entry up to m.max, or a group header with GroupByType.
+			row := msg.Y - m.OffsetY - strings.Count(m.headerView(), "\n")
+			index := m.entryAtRow(row)
+			if row < 0 || index < 0 {
+				break
+			}
+			double := index == m.lastClickIndex && time.Since(m.lastClick) < doubleClickInterval
+			m.selected = index
+			m.lastClick, m.lastClickIndex = time.Now(), index
+			if double {
+				m.lastClick = time.Time{}
+				m.notifyHighlight(highlighted)
+				cmd := m.choose()
+				return m, cmd
+			}
 		}
-		m.max = m.Height - 1
-		//m.Width = msg.Width // TODO: this line somehow breaks the filepicker
+		m.notifyHighlight(highlighted)
+
+	case fileOpMsg:
+		m.pushUndo(msg.entry)
+		return m, msg.read
+
+	case undoneMsg:
+		m.notice = fmt.Sprintf(m.Messages.Undone, msg.desc)
 
 	case tea.KeyMsg: // If msg is a KeyMsg, handle the key press.
+		m.err = nil
+		m.notice = ""
+		if m.inspecting {
+			m.inspecting = false
+			return m, nil
+		}
+		if m.renaming {
+			return m.updateRename(msg)
+		}
+		if m.creatingDir {
+			return m.updateMkDir(msg)
+		}
+		if m.enteringPath {
+			return m.updateEnterPath(msg)
+		}
+		if m.confirming != noConfirm {
+			return m.updateConfirm(msg)
+		}
+		if m.bookmarking != noBookmark {
+			return m.updateBookmark(msg)
+		}
+		if m.jumpingToLetter {
+			m.jumpingToLetter = false
+			if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && unicode.IsPrint(msg.Runes[0]) {
+				m.jumpToLetter(msg.Runes[0])
+				m.updatePathUIForSelection()
+			}
+			return m, nil
+		}
+		if m.updateFilter(msg) {
+			return m, nil
+		}
+
+		highlighted := m.selected
+
 		switch {
-		case key.Matches(msg, m.KeyMap.GoToTop): // If the msg matches the GoToTop keymap, go to the top of the file list.
+		case key.Matches(msg, m.KeyMap.Filter):
 
-			m.selected = 0       // Set the selected file to the first file.
-			m.min = 0            // Set the min to 0.
-			m.max = m.Height - 1 // Set the max to the height of the file picker.
+			m.filtering = true
 
-		case key.Matches(msg, m.KeyMap.GoToLast): // If the msg matches the GoToLast keymap, go to the last file in the list.
+		case key.Matches(msg, m.KeyMap.Sort):
 
-			m.selected = len(m.files) - 1   // Set the selected file to the last file.
-			m.min = len(m.files) - m.Height // Set the min to the length of the files minus the height of the file picker.
-			m.max = len(m.files) - 1        // Set the max to the length of the files minus 1.
+			m.SortMode = m.SortMode.next()
+			m.selected = 0
+			m.min = 0
+			m.max = m.Height - 1
+			return m, m.readDir()
 
-		case key.Matches(msg, m.KeyMap.Down): // If the msg matches the Down keymap, go down one file.
+		case key.Matches(msg, m.KeyMap.ToggleHidden):
 
-			m.selected++
-			if m.selected >= len(m.files) {
-				m.selected = len(m.files) - 1
+			// The number of entries changes, so start again from the top.
+			m.ShowHidden = !m.ShowHidden
+			m.dirCounts = nil
+			m.selected = 0
+			m.min = 0
+			m.max = m.Height - 1
+			return m, m.readDir()
+
+		case key.Matches(msg, m.KeyMap.SelectMode):
+
+			m.cycleSelectMode()
+
+		case key.Matches(msg, m.KeyMap.Reload):
+
+			// Keep the cursor on the same entry if it is still there.
+			if len(m.files) > 0 {
+				m.focusName = m.files[m.selected].Name()
 			}
-			if m.selected > m.max {
-				m.min++
-				m.max++
+			m.dirCounts = nil
+			return m, m.readDir()
+
+		case key.Matches(msg, m.KeyMap.Rename):
+
+			if len(m.files) == 0 || m.FileSystem != nil {
+				break
 			}
-			f := m.files[m.selected]
-			_, err := f.Info()
-			if err != nil {
+			m.renaming = true
+			m.renameInput = textinput.New()
+			m.renameInput.Prompt = m.Messages.Rename
+			// Entries of a Recursive listing are renamed within their own
+			// directory, so only the last element of the path is edited.
+			m.renameInput.SetValue(filepath.Base(m.files[m.selected].Name()))
+			return m, m.renameInput.Focus()
+
+		case key.Matches(msg, m.KeyMap.MkDir):
+
+			if m.FileSystem != nil {
 				break
 			}
-			isDir := f.IsDir()
+			m.creatingDir = true
+			m.mkdirInput = textinput.New()
+			m.mkdirInput.Prompt = m.Messages.NewDirectory
+			return m, m.mkdirInput.Focus()
 
-			if isDir {
-				m.PathUI = filepath.Join(m.CurrentDirectory)
-			} else {
+		case key.Matches(msg, m.KeyMap.EnterPath):
 
-				if m.FileAllowed {
-					m.PathUI = filepath.Join(m.CurrentDirectory, f.Name())
-				}
+			m.enteringPath = true
+			m.pathInput = textinput.New()
+			m.pathInput.Prompt = m.Messages.GoTo
+			return m, m.pathInput.Focus()
+
+		case key.Matches(msg, m.KeyMap.Delete):
+
+			n := len(m.targets())
+			if n == 0 || m.FileSystem != nil {
+				break
 			}
+			if n < m.ConfirmThreshold {
+				cmd := m.removeTargets()
+				return m, cmd
+			}
+			m.confirming = confirmDelete
 
-		case key.Matches(msg, m.KeyMap.Up):
+		case key.Matches(msg, m.KeyMap.CopyPath):
 
-			m.selected--
-			if m.selected < 0 {
-				m.selected = 0
+			if len(m.files) == 0 {
+				break
 			}
-			if m.selected < m.min {
-				m.min--
-				m.max--
+			path := m.join(m.files[m.selected].Name())
+			return m, func() tea.Msg {
+				if err := CopyPathToClipboard(path); err != nil {
+					return errorMsg{err}
+				}
+				return nil
 			}
 
-			f := m.files[m.selected]
-			_, err := f.Info()
-			if err != nil {
+		case key.Matches(msg, m.KeyMap.Yank):
+
+			m.yank(false)
+
+		case key.Matches(msg, m.KeyMap.Cut):
+
+			m.yank(true)
+
+		case key.Matches(msg, m.KeyMap.Paste):
+
+			if n := len(m.yanked); m.yankCut && n > 0 && n >= m.ConfirmThreshold && m.FileSystem == nil {
+				m.confirming = confirmMove
 				break
 			}
-			isDir := f.IsDir()
+			cmd := m.paste()
+			return m, cmd
 
-			if isDir {
-				m.PathUI = filepath.Join(m.CurrentDirectory)
-			} else {
+		case key.Matches(msg, m.KeyMap.Inspect):
+
+			m.inspect()
 
-				if m.FileAllowed {
-					m.PathUI = filepath.Join(m.CurrentDirectory, f.Name())
+		case key.Matches(msg, m.KeyMap.Undo):
+
+			cmd := m.undo()
+			return m, cmd
+
+		case key.Matches(msg, m.KeyMap.OpenExternal):
+
+			// Other applications can only open files on the local disk.
+			if len(m.files) == 0 || m.FileSystem != nil {
+				break
+			}
+			path := m.join(m.files[m.selected].Name())
+			return m, func() tea.Msg {
+				if err := OpenWithDefault(path); err != nil {
+					return errorMsg{err}
 				}
+				return nil
 			}
 
-		case key.Matches(msg, m.KeyMap.PageDown):
+		case key.Matches(msg, m.KeyMap.Help):
 
-			m.selected += m.Height
-			if m.selected >= len(m.files) {
-				m.selected = len(m.files) - 1
-			}
-			m.min += m.Height
-			m.max += m.Height
+			m.ShowHelp = !m.ShowHelp
+
+		case key.Matches(msg, m.KeyMap.SetBookmark):
 
-			if m.max >= len(m.files) {
-				m.max = len(m.files) - 1
-				m.min = m.max - m.Height
+			m.bookmarking = settingBookmark
+
+		case key.Matches(msg, m.KeyMap.JumpBookmark):
+
+			m.bookmarking = jumpingToBookmark
+
+		case key.Matches(msg, m.KeyMap.JumpLetter):
+
+			m.jumpingToLetter = true
+
+		case m.MultiSelect && (key.Matches(msg, m.KeyMap.Toggle) || key.Matches(msg, m.KeyMap.Select)):
+
+			// Selecting only marks entries until the selection is confirmed.
+			if len(m.files) == 0 {
+				break
 			}
+			if f := m.files[m.selected]; m.canSelect(f) {
+				m.toggleSelection(m.join(f.Name()))
+			}
+
+		case m.MultiSelect && key.Matches(msg, m.KeyMap.ConfirmSelection):
+
+			return m, m.confirmSelection()
+
+		case m.MultiSelect && key.Matches(msg, m.KeyMap.SelectAll):
+
+			m.markAll(true)
+
+		case m.MultiSelect && key.Matches(msg, m.KeyMap.DeselectAll):
+
+			m.markAll(false)
+
+		case m.MultiSelect && key.Matches(msg, m.KeyMap.InvertSelection):
+
+			m.invertSelection()
+
+		case key.Matches(msg, m.KeyMap.GoToTop): // If the msg matches the GoToTop keymap, go to the top of the file list.
+
+			m.SetCursor(0)
+			m.updatePathUIForSelection()
+
+		case key.Matches(msg, m.KeyMap.GoToLast): // If the msg matches the GoToLast keymap, go to the last file in the list.
+
+			m.SetCursor(len(m.files) - 1)
+			m.updatePathUIForSelection()
+
+		case key.Matches(msg, m.KeyMap.Down): // If the msg matches the Down keymap, go down one file.
+
+			m.moveDown()
+
+		case key.Matches(msg, m.KeyMap.Up):
+
+			m.moveUp()
+
+		case key.Matches(msg, m.KeyMap.PageDown):
+
+			m.MoveCursor(m.Height)
+			m.updatePathUIForSelection()
 
 		case key.Matches(msg, m.KeyMap.PageUp):
 
-			m.selected -= m.Height
-			if m.selected < 0 {
-				m.selected = 0
-			}
-			m.min -= m.Height
-			m.max -= m.Height
+			m.MoveCursor(-m.Height)
+			m.updatePathUIForSelection()
 
-			if m.min < 0 {
-				m.min = 0
-				m.max = m.min + m.Height
-			}
+		case key.Matches(msg, m.KeyMap.BackToRoot):
+
+			return m, m.backToRoot()
 
 		case key.Matches(msg, m.KeyMap.Back):
 
-			m.CurrentDirectory = filepath.Dir(m.CurrentDirectory)
+			if !m.allowed(m.parentDir()) {
+				break
+			}
+			// Put the cursor back on the directory we are leaving once the
+			// parent has been read.
+			m.focusName = filepath.Base(m.CurrentDirectory)
+			prev := m.CurrentDirectory
+			m.CurrentDirectory = m.parentDir()
+			m.notifyDirChange(prev)
 			m.PathUI = m.CurrentDirectory
+			m.filterValue = ""
 			if m.selectedStack.Length() > 0 {
 				m.selected, m.min, m.max = m.popView()
 			} else {
@@ -384,81 +1286,1065 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				m.min = 0
 				m.max = m.Height - 1
 			}
-			return m, readDir(m.CurrentDirectory, m.ShowHidden)
+			return m, m.readDir()
 
-		case key.Matches(msg, m.KeyMap.Open):
+		case key.Matches(msg, m.KeyMap.Select):
 
-			// if current dir is empty, do nothing
+			// Selecting never descends, so a directory that can't be selected
+			// is left alone.
 			if len(m.files) == 0 {
 				break
 			}
-
-			// The key press was a selection, let's confirm whether the current file could
-			// be selected or used for navigating deeper into the stack.
-			f := m.files[m.selected]
-			info, err := f.Info()
-			if err != nil {
+			if isDir, err := m.resolveDir(m.files[m.selected]); err != nil || (isDir && !m.DirAllowed) {
 				break
 			}
-			isSymlink := info.Mode()&os.ModeSymlink != 0
-			isDir := f.IsDir()
-
-			if isSymlink {
-				symlinkPath, _ := filepath.EvalSymlinks(filepath.Join(m.CurrentDirectory, f.Name()))
-				info, err := os.Stat(symlinkPath)
-				if err != nil {
-					break
-				}
-				if info.IsDir() {
-					isDir = true
-				}
-			}
+			return m, m.open(true)
 
-			if (!isDir && m.FileAllowed) || (isDir && m.DirAllowed) {
-				if key.Matches(msg, m.KeyMap.Select) {
-					// Select the current path as the selection
-					m.Path = filepath.Join(m.CurrentDirectory, f.Name())
-					return m, tea.Quit
-				}
-			}
+		case key.Matches(msg, m.KeyMap.Open):
 
-			if !isDir {
-				break
+			if m.isSelectKey(msg) {
+				cmd := m.choose()
+				return m, cmd
 			}
-
-			m.CurrentDirectory = filepath.Join(m.CurrentDirectory, f.Name())
-			m.PathUI = m.CurrentDirectory
-			m.pushView()
-			m.selected = 0
-			m.min = 0
-			m.max = m.Height - 1
-			return m, readDir(m.CurrentDirectory, m.ShowHidden)
+			return m, m.open(false)
 
 			//case key.Matches(msg, m.KeyMap.Quit):
 			//	return m, tea.Quit
+
+		default: // Any other printable key jumps to the next entry starting with it.
+			// Letters bound to other keys never get here, so JumpLetter
+			// followed by the letter is the way to jump to those.
+			if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && unicode.IsPrint(msg.Runes[0]) {
+				m.jumpToLetter(msg.Runes[0])
+				m.updatePathUIForSelection()
+			}
+		}
+		m.notifyHighlight(highlighted)
+
+	default: // Any other msg, e.g. a cursor blink, belongs to the active text input.
+		var cmd tea.Cmd
+		switch {
+		case m.renaming:
+			m.renameInput, cmd = m.renameInput.Update(msg)
+		case m.creatingDir:
+			m.mkdirInput, cmd = m.mkdirInput.Update(msg)
+		case m.enteringPath:
+			m.pathInput, cmd = m.pathInput.Update(msg)
 		}
+		return m, cmd
 	}
-	return m, nil
+	// Moving through the list may bring directories into view that need to
+	// be counted.
+	cmd := m.dirCountsCmd()
+	return m, cmd
 }
 
-// View returns the view of the file picker.
-func (m Model) View() string {
-	if len(m.files) == 0 {
-		return m.Styles.EmptyDirectory.String()
+// updateRename handles a key press while the rename input is open. Enter
+// renames the entry under the cursor and esc cancels.
+func (m Model) updateRename(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.renaming = false
+		return m, nil
+	case tea.KeyEnter:
+		m.renaming = false
+		if len(m.files) == 0 {
+			return m, nil
+		}
+		cmd := m.rename(m.files[m.selected].Name(), m.renameInput.Value())
+		return m, cmd
 	}
-	var s strings.Builder
+	var cmd tea.Cmd
+	m.renameInput, cmd = m.renameInput.Update(msg)
+	return m, cmd
+}
 
-	main := lipgloss.NewStyle().Width(50).Align(lipgloss.Center).Render(m.PathUI)
-	ui := lipgloss.JoinVertical(lipgloss.Center, main)
+// updateMkDir handles a key press while the new directory input is open.
+// Enter creates the directory and esc cancels.
+func (m Model) updateMkDir(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.creatingDir = false
+		return m, nil
+	case tea.KeyEnter:
+		m.creatingDir = false
+		name := m.mkdirInput.Value()
+		// Put the cursor on the new directory once the listing is refreshed.
+		m.focusName = name
+		cmd := m.mkdir(name)
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.mkdirInput, cmd = m.mkdirInput.Update(msg)
+	return m, cmd
+}
 
-	dialog := lipgloss.Place(m.Width, 4,
-		lipgloss.Center, lipgloss.Center,
-		m.Styles.MainBox.Render(ui),
-		lipgloss.WithWhitespaceForeground(subtle),
+// updateEnterPath handles key presses while the user types a directory to go
+// to. A relative path is taken relative to the current directory.
+func (m Model) updateEnterPath(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.enteringPath = false
+		return m, nil
+	case tea.KeyTab:
+		if !m.completePath() {
+			return m, nil
+		}
+		// The path is complete, so Tab goes there like enter does.
+		fallthrough
+	case tea.KeyEnter:
+		m.enteringPath = false
+		dir := m.pathInput.Value()
+		if dir == "" {
+			return m, nil
+		}
+		if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "~") {
+			dir = m.join(dir)
+		}
+		return m, m.GoTo(dir)
+	}
+	var cmd tea.Cmd
+	m.pathInput, cmd = m.pathInput.Update(msg)
+	return m, cmd
+}
+
+// failed returns the message of a file operation that failed with err, along
+// with the read of the directory that was started with the operation. The
+// picker is loading until that read arrives, so it has to run either way.
+func failed(read tea.Cmd, err error) tea.Msg {
+	return tea.BatchMsg{read, func() tea.Msg { return errorMsg{err} }}
+}
+
+// mkdir returns a command that creates the directory name within the current
+// directory and re-reads it.
+func (m *Model) mkdir(name string) tea.Cmd {
+	dir, messages := m.CurrentDirectory, m.Messages
+	read := m.readDir()
+	return func() tea.Msg {
+		if err := validName(name, messages); err != nil {
+			return failed(read, err)
+		}
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			return failed(read, err)
+		}
+		return read()
+	}
+}
+
+// updateBookmark handles the letter typed after a bookmark key. It either
+// saves the current directory under that letter or jumps to the directory
+// saved under it. Any key that isn't a letter cancels.
+func (m Model) updateBookmark(msg tea.KeyMsg) (Model, tea.Cmd) {
+	mode := m.bookmarking
+	m.bookmarking = noBookmark
+	if msg.Type != tea.KeyRunes || len(msg.Runes) != 1 || !unicode.IsLetter(msg.Runes[0]) {
+		return m, nil
+	}
+	letter := msg.Runes[0]
+
+	if mode == settingBookmark {
+		if m.Bookmarks == nil {
+			m.Bookmarks = map[rune]string{}
+		}
+		m.Bookmarks[letter] = m.CurrentDirectory
+		return m, nil
+	}
+
+	dir, ok := m.Bookmarks[letter]
+	if !ok {
+		return m, nil
+	}
+	return m, m.jumpTo(dir)
+}
+
+// jumpTo changes the current directory to dir, which doesn't have to be
+// related to the current one, and returns the command to read it. The view
+// stacks are reset since going back no longer retraces the path taken. If dir
+// is not an existing directory, the returned command reports an error instead.
+func (m *Model) jumpTo(dir string) tea.Cmd {
+	info, err := m.stat(dir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf(m.Messages.NotADirectory, dir)
+	}
+	if err == nil && !m.allowed(dir) {
+		err = fmt.Errorf(m.Messages.OutsideRoot, dir, m.root())
+	}
+	if err != nil {
+		return func() tea.Msg { return errorMsg{err} }
+	}
+
+	prev := m.CurrentDirectory
+	m.CurrentDirectory = dir
+	m.notifyDirChange(prev)
+	m.PathUI = dir
+	m.filterValue = ""
+	m.selectedStack = newStack()
+	m.minStack = newStack()
+	m.maxStack = newStack()
+	m.selected = 0
+	m.min = 0
+	m.max = m.Height - 1
+	return m.readDir()
+}
+
+// root returns RootDirectory, or the initial directory if it is not set.
+func (m Model) root() string {
+	if m.RootDirectory != "" {
+		return m.RootDirectory
+	}
+	return m.initialDirectory
+}
+
+// confined reports whether the user has to stay within the root directory.
+func (m Model) confined() bool {
+	return (m.ConfineToRoot || m.RootDirectory != "") && m.root() != ""
+}
+
+// allowed reports whether the user may navigate to dir, which is always the
+// case unless the picker is confined to the root directory.
+func (m Model) allowed(dir string) bool {
+	return !m.confined() || within(m.root(), dir)
+}
+
+// checkSymlinkEscape returns an error if the picker is confined to the root
+// directory and the symlink name in the current directory resolves to a
+// directory outside of it.
+func (m Model) checkSymlinkEscape(name string) error {
+	if !m.confined() {
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(m.join(name))
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(m.root())
+	if err != nil {
+		return err
+	}
+	if !within(root, target) {
+		return fmt.Errorf(m.Messages.LinkOutsideRoot, name, target, m.root())
+	}
+	return nil
+}
+
+// backToRoot returns to the root directory, with the cursor on the entry
+// that leads to the current directory.
+func (m *Model) backToRoot() tea.Cmd {
+	root := m.root()
+	if root == "" || root == m.CurrentDirectory {
+		return nil
+	}
+	var focus string
+	if within(root, m.CurrentDirectory) {
+		if rel, err := filepath.Rel(root, m.CurrentDirectory); err == nil {
+			focus = strings.Split(filepath.ToSlash(rel), "/")[0]
+		}
+	}
+	cmd := m.jumpTo(root)
+	m.focusName = focus
+	return cmd
+}
+
+// GoTo changes the current directory to dir and returns the command to read
+// it, which reports an error instead if dir is not an existing directory. On
+// the local disk, dir is expanded with ExpandPath.
+func (m *Model) GoTo(dir string) tea.Cmd {
+	if m.FileSystem == nil {
+		expanded, err := ExpandPath(dir)
+		if err != nil {
+			return func() tea.Msg { return errorMsg{err} }
+		}
+		dir = expanded
+	}
+	return m.jumpTo(dir)
+}
+
+// Home changes the current directory to the home directory of the user, or to
+// the root of FileSystem if set, and returns the command to read it.
+func (m *Model) Home() tea.Cmd {
+	if m.FileSystem != nil {
+		return m.jumpTo(".")
+	}
+	return m.GoTo("~")
+}
+
+// SetCurrentDirectory makes dir the directory the picker starts in, with the
+// cursor on its first entry. It is meant to be called before Init, which reads
+// it, and returns an error if dir is not an existing directory. On the local
+// disk, dir is expanded with ExpandPath.
+func (m *Model) SetCurrentDirectory(dir string) error {
+	if m.FileSystem == nil {
+		expanded, err := ExpandPath(dir)
+		if err != nil {
+			return err
+		}
+		dir = expanded
+	}
+	info, err := m.stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf(m.Messages.NotADirectory, dir)
+	}
+
+	m.CurrentDirectory = dir
+	m.PathUI = dir
+	m.filterValue = ""
+	m.selectedStack = newStack()
+	m.minStack = newStack()
+	m.maxStack = newStack()
+	m.selected = 0
+	m.min = 0
+	m.max = m.Height - 1
+	return nil
+}
+
+// bookmarksView returns a hint line listing the bookmarks, or an empty string
+// when there are none.
+func (m Model) bookmarksView() string {
+	if len(m.Bookmarks) == 0 {
+		return ""
+	}
+	letters := make([]rune, 0, len(m.Bookmarks))
+	for letter := range m.Bookmarks {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+
+	hints := make([]string, len(letters))
+	for i, letter := range letters {
+		hints[i] = fmt.Sprintf("%c %s", letter, m.Bookmarks[letter])
+	}
+	return m.Styles.Bookmarks.Render(m.Messages.Bookmarks+strings.Join(hints, "  ")) + "\n\n"
+}
+
+// updateConfirm handles the answer to the confirmation prompt. Only y
+// confirms, any other key cancels and returns to normal navigation.
+func (m Model) updateConfirm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	action := m.confirming
+	m.confirming = noConfirm
+	if msg.String() != "y" && msg.String() != "Y" {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	switch action {
+	case confirmDelete:
+		cmd = m.removeTargets()
+	case confirmMove:
+		cmd = m.paste()
+	}
+	return m, cmd
+}
+
+// confirmPrompt returns the question of the confirmation prompt, naming the
+// entry it is about or, for several, telling how many there are and the
+// first few of their names.
+func (m Model) confirmPrompt() string {
+	one, many, paths := m.Messages.ConfirmDelete, m.Messages.ConfirmDeleteMany, m.targets()
+	if m.confirming == confirmMove {
+		one, many, paths = m.Messages.ConfirmMove, m.Messages.ConfirmMoveMany, m.yanked
+	}
+	if len(paths) == 1 {
+		return fmt.Sprintf(one, filepath.Base(paths[0]))
+	}
+	return fmt.Sprintf(many, len(paths), sampleNames(paths))
+}
+
+// removeTargets returns a command that deletes the marked entries, or the
+// entry under the cursor if none are marked, and unmarks them as they are
+// gone.
+func (m *Model) removeTargets() tea.Cmd {
+	paths := m.targets()
+	if len(paths) == 0 {
+		return nil
+	}
+	m.clearSelection()
+	return m.remove(paths, m.DirAllowed)
+}
+
+// remove returns a command that deletes paths and re-reads the current
+// directory. If recursive is true, directories are removed with everything
+// they contain, otherwise only empty directories can be removed. With
+// UseTrash, they are moved to the trash instead. Entries that fail don't stop
+// the others, and their errors are reported together.
+func (m *Model) remove(paths []string, recursive bool) tea.Cmd {
+	useTrash := m.UseTrash
+	messages := m.Messages
+	read := m.readDir()
+	return func() tea.Msg {
+		var trashed []fileOp
+		var names []string
+		var errs []error
+		for _, path := range paths {
+			var trashErr error
+			if useTrash {
+				to, info, err := trash(path)
+				if err == nil {
+					// The Recycle Bin doesn't say where the entry went, so
+					// the move can't be undone.
+					if to != "" {
+						trashed = append(trashed, fileOp{from: path, to: to, info: info})
+						names = append(names, filepath.Base(path))
+					}
+					continue
+				}
+				// Only delete the entry for good if there is no trash at
+				// all. Otherwise it is left alone.
+				if !errors.Is(err, errTrashUnavailable) {
+					errs = append(errs, err)
+					continue
+				}
+				trashErr = err
+			}
+			remove := os.Remove
+			if recursive {
+				remove = os.RemoveAll
+			}
+			err := remove(path)
+			switch {
+			case err != nil && trashErr != nil:
+				errs = append(errs, fmt.Errorf(messages.TrashDeleteFailed, filepath.Base(path), trashErr, err))
+			case err != nil:
+				errs = append(errs, err)
+			case trashErr != nil:
+				// Say that the entry is gone for good.
+				errs = append(errs, fmt.Errorf(messages.TrashDeleted, filepath.Base(path), trashErr))
+			}
+		}
+
+		msgs := tea.BatchMsg{read}
+		if len(trashed) > 0 {
+			entry := undoEntry{desc: fmt.Sprintf(messages.UndoTrash, strings.Join(names, ", ")), ops: trashed}
+			msgs = tea.BatchMsg{func() tea.Msg { return fileOpMsg{entry: entry, read: read} }}
+		}
+		if err := joinErrors(errs, messages); err != nil {
+			msgs = append(msgs, func() tea.Msg { return errorMsg{err} })
+		}
+		return msgs
+	}
+}
+
+// rename returns a command that renames the entry oldName of the current
+// directory to newName and re-reads it. oldName may be a path relative to the
+// current directory, as in a Recursive listing, and the entry keeps its
+// parent directory. It refuses to overwrite an existing entry.
+func (m *Model) rename(oldName, newName string) tea.Cmd {
+	oldPath, messages := filepath.Join(m.CurrentDirectory, oldName), m.Messages
+	desc := fmt.Sprintf(messages.UndoRename, oldName, newName)
+	read := m.readDir()
+	return func() tea.Msg {
+		if newName == filepath.Base(oldName) {
+			return read()
+		}
+		if err := validName(newName, messages); err != nil {
+			return failed(read, err)
+		}
+		newPath := filepath.Join(filepath.Dir(oldPath), newName)
+		if _, err := os.Lstat(newPath); err == nil {
+			return failed(read, fmt.Errorf(messages.AlreadyExists, newName))
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return failed(read, err)
+		}
+		entry := undoEntry{desc: desc, ops: []fileOp{{from: oldPath, to: newPath}}}
+		return fileOpMsg{entry: entry, read: read}
+	}
+}
+
+// validName returns an error in the words of messages if name can't be used
+// as the name of an entry in a directory.
+func validName(name string, messages Messages) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf(messages.InvalidName, name)
+	}
+	return nil
+}
+
+// moveDown moves the cursor to the next entry, scrolling the view if needed.
+func (m *Model) moveDown() {
+	if len(m.files) == 0 {
+		return
+	}
+	if m.selected == len(m.files)-1 && m.WrapNavigation {
+		// Wrap around to the first entry.
+		m.SetCursor(0)
+	} else {
+		m.MoveCursor(1)
+	}
+	m.updatePathUIForSelection()
+}
+
+// moveUp moves the cursor to the previous entry, scrolling the view if needed.
+func (m *Model) moveUp() {
+	if len(m.files) == 0 {
+		return
+	}
+	if m.selected == 0 && m.WrapNavigation {
+		// Wrap around to the last entry.
+		m.SetCursor(len(m.files) - 1)
+	} else {
+		m.MoveCursor(-1)
+	}
+	m.updatePathUIForSelection()
+}
+
+// updatePathUIForSelection shows the entry under the cursor in the header: the
+// current directory for a directory, and the path of a file if files can be
+// selected.
+func (m *Model) updatePathUIForSelection() {
+	if len(m.files) == 0 {
+		return
+	}
+	f := m.files[m.selected]
+	if _, err := f.Info(); err != nil {
+		return
+	}
+	if f.IsDir() {
+		m.PathUI = m.CurrentDirectory
+	} else if m.FileAllowed {
+		m.PathUI = m.join(f.Name())
+	}
+}
+
+// open descends into the directory under the cursor. If selecting is true
+// and the entry may be selected, it is selected instead and the returned
+// command quits the program.
+func (m *Model) open(selecting bool) tea.Cmd {
+	// if current dir is empty, do nothing
+	if len(m.files) == 0 {
+		return nil
+	}
+
+	// The key press was a selection, let's confirm whether the current file could
+	// be selected or used for navigating deeper into the stack.
+	m.lastErr = nil
+	f := m.files[m.selected]
+	info, err := f.Info()
+	if err != nil {
+		m.lastErr = err
+		return nil
+	}
+	isSymlink := info.Mode()&os.ModeSymlink != 0
+	isDir := f.IsDir()
+
+	if isSymlink && m.FollowSymlinks {
+		info, err := m.stat(m.join(f.Name()))
+		if err != nil {
+			m.lastErr = err
+			return nil
+		}
+		if info.IsDir() {
+			isDir = true
+		}
+	}
+
+	if m.canSelect(f) {
+		if selecting {
+			// Select the current path as the selection
+			m.Path = m.join(f.Name())
+			if m.onSelect != nil {
+				m.onSelect(m.Path)
+			}
+			// The picker is done, so stop watching the directory.
+			_ = m.Close()
+			return tea.Quit
+		}
+	}
+
+	if !isDir {
+		return nil
+	}
+
+	// A symlink to the current directory or one of its ancestors would let
+	// the user descend forever, and one that leads out of the root directory
+	// would break the confinement, so neither is followed.
+	if isSymlink && m.FileSystem == nil {
+		if err := m.checkSymlinkLoop(f.Name()); err != nil {
+			return func() tea.Msg { return errorMsg{err} }
+		}
+		if err := m.checkSymlinkEscape(f.Name()); err != nil {
+			return func() tea.Msg { return errorMsg{err} }
+		}
+	}
+
+	m.CurrentDirectory = m.join(f.Name())
+	m.notifyDirChange(m.parentDir())
+	m.PathUI = m.CurrentDirectory
+	m.filterValue = ""
+	m.pushView()
+	m.selected = 0
+	m.min = 0
+	m.max = m.Height - 1
+	return m.readDir()
+}
+
+// checkSymlinkLoop returns an error if the symlink name in the current
+// directory resolves to the current directory or one of its ancestors.
+func (m Model) checkSymlinkLoop(name string) error {
+	target, err := filepath.EvalSymlinks(m.join(name))
+	if err != nil {
+		return err
+	}
+	current, err := filepath.EvalSymlinks(m.CurrentDirectory)
+	if err != nil {
+		return err
+	}
+	if within(target, current) {
+		return fmt.Errorf(m.Messages.LinkLoop, name, target)
+	}
+	return nil
+}
+
+// within reports whether path is dir or lies inside it. Relative paths are
+// taken relative to the working directory.
+func within(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// join returns the path of the entry name in the current directory.
+func (m Model) join(name string) string {
+	if m.FileSystem != nil {
+		return path.Join(m.CurrentDirectory, name)
+	}
+	return filepath.Join(m.CurrentDirectory, name)
+}
+
+// parentDir returns the path of the parent of the current directory.
+func (m Model) parentDir() string {
+	return m.parentDirOf(m.CurrentDirectory)
+}
+
+// parentDirOf returns the path of the parent of dir.
+func (m Model) parentDirOf(dir string) string {
+	if m.FileSystem != nil {
+		return path.Dir(dir)
+	}
+	return filepath.Dir(dir)
+}
+
+// stat returns the file info of name, following symlinks, from FileSystem if
+// set and from the local disk otherwise.
+func (m Model) stat(name string) (os.FileInfo, error) {
+	if m.FileSystem != nil {
+		return fs.Stat(m.FileSystem, name)
+	}
+	return os.Stat(name)
+}
+
+// focusEntry moves the cursor to the entry called name and scrolls the view
+// so that it is visible. It reports whether such an entry was found.
+func (m *Model) focusEntry(name string) bool {
+	for i, f := range m.files {
+		if f.Name() != name {
+			continue
+		}
+		m.selected = i
+		m.ensureVisible()
+		return true
+	}
+	return false
+}
+
+// jumpToLetter moves the cursor to the next entry whose name starts with
+// letter, ignoring case, and wraps around at the end of the list so repeated
+// presses cycle through all matches.
+func (m *Model) jumpToLetter(letter rune) {
+	letter = unicode.ToLower(letter)
+	for i := 1; i <= len(m.files); i++ {
+		index := (m.selected + i) % len(m.files)
+		first, _ := utf8.DecodeRuneInString(m.files[index].Name())
+		if unicode.ToLower(first) == letter {
+			m.selected = index
+			m.ensureVisible()
+			return
+		}
+	}
+}
+
+// MoveCursor moves the cursor by delta entries, down if it is positive and up
+// if it is negative, stopping at the first and last entry. If the cursor
+// leaves the view, the view scrolls by as much as the cursor moved, so paging
+// keeps the cursor on the same row.
+func (m *Model) MoveCursor(delta int) {
+	if len(m.files) == 0 {
+		return
+	}
+	index := m.selected + delta
+	if index >= len(m.files) {
+		index = len(m.files) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	if index < m.min || index > m.max {
+		m.min += index - m.selected
+	}
+	m.SetCursor(index)
+}
+
+// SetCursor puts the cursor on the entry at index, clamped to the listed
+// entries, and scrolls the view as little as needed to show it.
+func (m *Model) SetCursor(index int) {
+	if len(m.files) == 0 {
+		return
+	}
+	if index >= len(m.files) {
+		index = len(m.files) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	m.selected = index
+	// Don't leave empty rows below the last entry or scroll above the first.
+	if last := m.firstShown(len(m.files) - 1); m.min > last {
+		m.min = last
+	}
+	if m.min < 0 {
+		m.min = 0
+	}
+	m.ensureVisible()
+}
+
+// ensureVisible scrolls the view so that the entry under the cursor is
+// visible.
+func (m *Model) ensureVisible() {
+	if m.selected < m.min {
+		m.min = m.selected
+	}
+	if m.selected > m.lastShown(m.min) {
+		m.min = m.firstShown(m.selected)
+	}
+	m.max = m.lastShown(m.min)
+}
+
+// clampView keeps the cursor and the visible window within the bounds of the
+// current listing, e.g. after re-reading a directory that has shrunk.
+func (m *Model) clampView() {
+	n := len(m.files)
+	if m.selected >= n {
+		m.selected = n - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+	if last := m.firstShown(n - 1); m.min > last {
+		m.min = last
+	}
+	if m.min > m.selected {
+		m.min = m.selected
+	}
+	if m.min < 0 {
+		m.min = 0
+	}
+	m.max = m.lastShown(m.min)
+	if m.selected > m.max {
+		m.min = m.firstShown(m.selected)
+		m.max = m.lastShown(m.min)
+	}
+}
+
+// resolveDir reports whether the entry is a directory, following symlinks
+// unless FollowSymlinks is false.
+func (m Model) resolveDir(f os.DirEntry) (bool, error) {
+	info, err := f.Info()
+	if err != nil {
+		return false, err
+	}
+	if info.Mode()&os.ModeSymlink == 0 || !m.FollowSymlinks {
+		return f.IsDir(), nil
+	}
+	info, err = m.stat(m.join(f.Name()))
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// toggleSelection marks path if it isn't marked yet and unmarks it otherwise.
+func (m *Model) toggleSelection(path string) {
+	if m.selectedFiles == nil {
+		m.selectedFiles = map[string]struct{}{}
+	}
+	if _, ok := m.selectedFiles[path]; !ok {
+		m.selectedFiles[path] = struct{}{}
+		m.selectionOrder = append(m.selectionOrder, path)
+		return
+	}
+	delete(m.selectedFiles, path)
+	for i, p := range m.selectionOrder {
+		if p == path {
+			m.selectionOrder = append(m.selectionOrder[:i:i], m.selectionOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// markAll marks or unmarks every listed entry that can be selected. Entries
+// hidden by the filter are left as they are.
+func (m *Model) markAll(marked bool) {
+	for _, f := range m.files {
+		if !m.canSelect(f) {
+			continue
+		}
+		path := m.join(f.Name())
+		if _, ok := m.selectedFiles[path]; ok != marked {
+			m.toggleSelection(path)
+		}
+	}
+}
+
+// invertSelection marks the listed entries that can be selected but aren't
+// marked, and unmarks the ones that are.
+func (m *Model) invertSelection() {
+	for _, f := range m.files {
+		if m.canSelect(f) {
+			m.toggleSelection(m.join(f.Name()))
+		}
+	}
+}
+
+// confirmSelection ends the selection in MultiSelect mode and returns the
+// command that quits the program. If nothing is marked, the entry under the
+// cursor is selected, and if it can't be, the selection goes on.
+func (m *Model) confirmSelection() tea.Cmd {
+	if len(m.selectionOrder) == 0 && len(m.files) > 0 && m.canSelect(m.files[m.selected]) {
+		m.toggleSelection(m.join(m.files[m.selected].Name()))
+	}
+	if len(m.selectionOrder) == 0 {
+		return nil
+	}
+	if m.onSelect != nil {
+		for _, path := range m.selectionOrder {
+			m.onSelect(path)
+		}
+	}
+	// The picker is done, so stop watching the directory.
+	_ = m.Close()
+	return tea.Quit
+}
+
+// choose selects the entry under the cursor, or in MultiSelect mode ends the
+// selection like the ConfirmSelection key, so the entry under the cursor
+// only counts if nothing is marked. A directory that isn't selected is opened
+// instead.
+func (m *Model) choose() tea.Cmd {
+	if !m.MultiSelect {
+		return m.open(true)
+	}
+	if cmd := m.confirmSelection(); cmd != nil {
+		return cmd
+	}
+	return m.open(false)
+}
+
+// updateFilter handles a key press while the filter input is open or a filter
+// is applied, and reports whether the key was consumed. Keys that don't edit
+// the query, such as the arrow keys, fall through to regular navigation.
+func (m *Model) updateFilter(msg tea.KeyMsg) bool {
+	if msg.Type == tea.KeyEsc && (m.filtering || m.filterValue != "") {
+		m.filtering = false
+		m.filterValue = ""
+		m.applyFilter()
+		return true
+	}
+	if !m.filtering {
+		return false
+	}
+
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyBackspace:
+		if runes := []rune(m.filterValue); len(runes) > 0 {
+			m.filterValue = string(runes[:len(runes)-1])
+			m.applyFilter()
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.filterValue += string(msg.Runes)
+		m.applyFilter()
+	case tea.KeyTab:
+		m.completeFilter()
+	default:
+		return false
+	}
+	return true
+}
+
+// applyFilter narrows the listing down to the entries matching the current
+// filter query and moves the cursor back to the top of the new list.
+func (m *Model) applyFilter() {
+	m.files = filterEntries(m.allFiles, m.filterValue)
+	m.selected = 0
+	m.min = 0
+	m.ensureVisible()
+}
+
+// filterEntries returns the entries whose names contain query, ignoring case.
+func filterEntries(entries []os.DirEntry, query string) []os.DirEntry {
+	if query == "" {
+		return entries
+	}
+	query = strings.ToLower(query)
+	var filtered []os.DirEntry
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Name()), query) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// InputActive reports whether the file picker is currently consuming key
+// presses as text input, e.g. while typing a filter query or a new name.
+// Parent models should not treat single-letter keys as shortcuts while this
+// is true.
+func (m Model) InputActive() bool {
+	return m.filtering || m.renaming || m.creatingDir || m.enteringPath || m.confirming != noConfirm || m.inspecting || m.bookmarking != noBookmark || m.jumpingToLetter
+}
+
+// promptView returns the line of the active text input, e.g. the filter query
+// or the new name of a renamed entry, followed by the last error if any.
+func (m Model) promptView() string {
+	var s string
+	switch {
+	case m.renaming:
+		s = strings.Repeat(" ", paddingLeft) + m.renameInput.View() + "\n\n"
+	case m.creatingDir:
+		s = strings.Repeat(" ", paddingLeft) + m.mkdirInput.View() + "\n\n"
+	case m.enteringPath:
+		s = strings.Repeat(" ", paddingLeft) + m.pathInput.View() + "\n\n"
+	case m.bookmarking == settingBookmark:
+		s = m.Styles.Filter.Render(m.Messages.SetBookmark) + "\n\n"
+	case m.bookmarking == jumpingToBookmark:
+		s = m.Styles.Filter.Render(m.Messages.JumpToBookmark) + "\n\n"
+	case m.jumpingToLetter:
+		s = m.Styles.Filter.Render(m.Messages.JumpToLetter) + "\n\n"
+	case m.confirming != noConfirm:
+		s = m.Styles.Confirm.Render(m.confirmPrompt()) + "\n\n"
+	case m.filtering || m.filterValue != "":
+		s = m.Styles.Filter.Render("/"+m.filterValue) + "\n\n"
+	}
+	if m.err != nil {
+		s += m.Styles.Error.Render(m.Messages.ErrorPrefix+m.err.Error()) + "\n\n"
+	}
+	if m.notice != "" {
+		s += m.Styles.Notice.Render(m.notice) + "\n\n"
+	}
+	return s
+}
+
+// breadcrumbView returns the segments of the current directory joined by the
+// breadcrumb separator. If they don't fit in the width of the file picker, the
+// segments after the first one are collapsed into an ellipsis, starting with
+// the outermost, so the innermost directories remain visible.
+func (m Model) breadcrumbView() string {
+	dir := filepath.ToSlash(m.CurrentDirectory)
+	var segments []string
+	if strings.HasPrefix(dir, "/") {
+		segments = append(segments, "/")
+	}
+	if trimmed := strings.Trim(dir, "/"); trimmed != "" {
+		segments = append(segments, strings.Split(trimmed, "/")...)
+	}
+
+	render := func(segments []string) string {
+		styled := make([]string, len(segments))
+		for i, segment := range segments {
+			styled[i] = m.Styles.Breadcrumb.Render(segment)
+		}
+		return strings.Repeat(" ", paddingLeft) + strings.Join(styled, m.Styles.BreadcrumbSeparator.String())
+	}
+
+	line := render(segments)
+	for n := 2; m.Width > 0 && lipgloss.Width(line) > m.Width && n < len(segments); n++ {
+		collapsed := append([]string{segments[0], ellipsis}, segments[n:]...)
+		line = render(collapsed)
+	}
+	return line
+}
+
+// headerView returns everything rendered above the file list.
+func (m Model) headerView() string {
+	if m.BreadcrumbMode {
+		return m.breadcrumbView() + m.badgeView() + "\n\n" + m.bookmarksView() + m.promptView()
+	}
+	if !m.ShowPathBox {
+		return m.Styles.MainPath.Render(m.PathUI) + m.badgeView() + "\n\n" + m.bookmarksView() + m.promptView()
+	}
+
+	// The path is centered in a box of pathBoxWidth columns that shrinks to fit
+	// narrow terminals, borders included.
+	width := pathBoxWidth
+	if inner := m.Width - m.Styles.MainBox.GetHorizontalFrameSize(); m.Width > 0 && inner < width {
+		width = inner
+	}
+	main := lipgloss.NewStyle().Width(width).Align(lipgloss.Center).Render(m.PathUI + m.badgeView())
+	ui := lipgloss.JoinVertical(lipgloss.Center, main)
+
+	var whitespace []lipgloss.WhitespaceOption
+	if !m.colorsDisabled {
+		whitespace = append(whitespace, lipgloss.WithWhitespaceForeground(subtle))
+	}
+	dialog := lipgloss.Place(m.Width, 4,
+		lipgloss.Center, lipgloss.Center,
+		m.Styles.MainBox.Render(ui),
+		whitespace...,
 	)
 
-	s.WriteString(dialog + "\n\n")
+	return dialog + "\n\n" + m.bookmarksView() + m.promptView()
+}
 
+// badgeView returns the number of marked entries shown after the path in
+// MultiSelect mode, or nothing if none are marked.
+func (m Model) badgeView() string {
+	if !m.MultiSelect || len(m.selectionOrder) == 0 {
+		return ""
+	}
+	return " " + m.Styles.Badge.Render(fmt.Sprintf(m.Messages.Selected, len(m.selectionOrder)))
+}
+
+// View returns the view of the file picker.
+func (m Model) View() string {
+	// Re-reading the listed directory keeps showing its entries meanwhile.
+	if m.loading && m.CurrentDirectory != m.shownDirectory {
+		return m.headerView() + m.Styles.Loading.Render(m.spinner.View()+" "+m.Messages.Loading) + "\n" + m.helpView()
+	}
+	if len(m.files) == 0 {
+		empty := m.Styles.EmptyDirectory
+		if m.EmptyMessage != "" {
+			empty = empty.SetString(m.EmptyMessage)
+		} else if m.Messages.EmptyDirectory != "" {
+			empty = empty.SetString(m.Messages.EmptyDirectory)
+		}
+		return m.promptView() + empty.String() + m.clipboardView() + m.helpView()
+	}
+	if m.inspecting {
+		return m.headerView() + m.inspectView() + m.helpView()
+	}
+	var s strings.Builder
+
+	s.WriteString(m.headerView())
+
+	// The scrollbar takes up the last columns, so names have to end before it.
+	width := m.listWidth()
+
+	// Rows without the cursor are indented by its width to stay aligned.
+	gutter := strings.Repeat(" ", m.cursorWidth())
+
+	var list strings.Builder
 	for i, f := range m.files {
 		// Skip files that are out of the range of the current view.
 		if i < m.min {
@@ -468,57 +2354,487 @@ func (m Model) View() string {
 		if i > m.max {
 			break
 		}
-		// symlinkPath is the path that the symlink points to.
-		var symlinkPath string
-		info, _ := f.Info()
-		isSymlink := info.Mode()&os.ModeSymlink != 0
-		size := humanize.Bytes(uint64(info.Size()))
-		name := f.Name()
-
-		// If the file is a symlink, get the path that it points to.
-		if isSymlink {
-			symlinkPath, _ = filepath.EvalSymlinks(filepath.Join(m.CurrentDirectory, name))
+		if m.groupHeaderBefore(i) {
+			list.WriteString(m.Styles.GroupHeader.Render(groupOf(f).name(m.Messages)) + "\n")
 		}
-
-		// If the file is disabled, it cannot be selected.
-		// User can define which file types are allowed to be selected via the AllowedTypes field.
-		disabled := !m.canSelect(name) && !f.IsDir()
+		r := m.entryRow(f)
+		name := r.name
 
 		if m.selected == i {
-			selected := fmt.Sprintf(" %s %"+fmt.Sprint(m.Styles.FileSize.GetWidth())+"s %s", info.Mode().String(), size, name)
-			if isSymlink {
-				selected = fmt.Sprintf("%s → %s", selected, symlinkPath)
+			prefix, label, suffix, space := m.selectedLabel(r, width)
+			if m.TruncateNames && m.Width > 0 {
+				label = m.scrolledLabel(label, space)
 			}
-			if disabled {
-				s.WriteString(m.Styles.DisabledSelected.Render(m.Cursor) + m.Styles.DisabledSelected.Render(selected))
+			selected := prefix + label + suffix
+			if r.disabled {
+				list.WriteString(m.cursorView(true) + r.marker + m.Styles.DisabledSelected.Render(selected))
 			} else {
-				s.WriteString(m.Styles.Cursor.Render(m.Cursor) + m.Styles.Selected.Render(selected))
+				list.WriteString(m.cursorView(false) + r.marker + m.Styles.Selected.Render(selected))
 			}
-			s.WriteRune('\n')
+			list.WriteRune('\n')
 			continue
 		}
 
 		// Select the correct style for the name.
-		style := m.Styles.File
+		style := m.fileStyle(name)
 		if f.IsDir() {
 			style = m.Styles.Directory
-		} else if isSymlink {
+		} else if r.isSymlink {
 			style = m.Styles.Symlink
-		} else if disabled {
+		} else if r.disabled {
 			style = m.Styles.DisabledFile
 		}
+		if !r.disabled && m.gitStatusDir == m.CurrentDirectory {
+			switch m.gitStatuses[name] {
+			case gitModified:
+				style = m.Styles.GitModified
+			case gitUntracked:
+				style = m.Styles.GitUntracked
+			case gitStaged:
+				style = m.Styles.GitStaged
+			}
+		}
+
+		var suffix string
+		if r.indicator != "" {
+			suffix = m.Styles.Indicator.Render(r.indicator)
+		}
+		if r.count != "" {
+			suffix += m.Styles.DirCount.Render(r.count)
+		}
+		if r.selectableDir {
+			suffix += m.Styles.SelectableDirectory.String()
+		}
+		if r.permission != "" {
+			r.permission = m.Styles.Permission.Render(strings.TrimSuffix(r.permission, " ")) + " "
+		}
+		row := fmt.Sprintf("%s%s%s%s%s ", gutter, r.marker, r.permission, r.sizeColumn, r.modTimeColumn)
+		if m.Compact {
+			row = gutter + r.marker + " "
+		}
+
+		fileName := style.Render(m.shownName(name, width-lipgloss.Width(row+r.icon+suffix)-r.targetWidth))
+		if m.ShowIcons {
+			iconStyle := m.fileStyle(name)
+			if f.IsDir() {
+				iconStyle = m.Styles.Directory
+			} else if r.isSymlink {
+				iconStyle = m.Styles.Symlink
+			}
+			fileName = iconStyle.Render(r.icon) + fileName
+		}
+		if r.isSymlink {
+			fileName = fmt.Sprintf("%s → %s", fileName, r.symlinkPath)
+		}
+		if nameWidth := width - lipgloss.Width(row+suffix); m.TruncateNames && m.Width > 0 {
+			if nameWidth < 1 {
+				nameWidth = 1
+			}
+			fileName = truncateName(fileName, nameWidth)
+		}
+		list.WriteString(row + fileName + suffix)
+		list.WriteRune('\n')
+	}
 
-		fileName := style.Render(name)
-		if isSymlink {
-			fileName = fmt.Sprintf("%s → %s", fileName, symlinkPath)
+	if m.ShowScrollbar {
+		rows := strings.TrimSuffix(list.String(), "\n")
+		if width > 0 {
+			rows = lipgloss.PlaceHorizontal(width, lipgloss.Left, rows)
 		}
-		s.WriteString(fmt.Sprintf("  %s %s %s", m.Styles.Permission.Render(info.Mode().String()), m.Styles.FileSize.Render(size), fileName))
+		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, rows, " ", m.scrollbarView(strings.Count(rows, "\n")+1)))
 		s.WriteRune('\n')
+	} else {
+		s.WriteString(list.String())
 	}
 
+	s.WriteString(m.scrollIndicatorView())
+	s.WriteString(m.treeNoticeView())
+	s.WriteString(m.clipboardView())
+	s.WriteString(m.helpView())
 	return s.String()
 }
 
+// truncateName cuts s down to width and ends it with an ellipsis if it
+// doesn't fit. It keeps ANSI sequences and cuts on rune boundaries.
+func truncateName(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	return truncate.StringWithTail(s, uint(width), ellipsis)
+}
+
+// shownName returns the name of an entry as View shows it: with ShowFullPaths,
+// its full path, with the start of the path elided to fit into width if names
+// are truncated, so the base name stays visible.
+func (m Model) shownName(name string, width int) string {
+	if !m.ShowFullPaths {
+		return name
+	}
+	full := m.join(name)
+	if !m.TruncateNames || m.Width <= 0 || lipgloss.Width(full) <= width {
+		return full
+	}
+	runes := []rune(full)
+	for i := range runes {
+		if rest := ellipsis + string(runes[i:]); lipgloss.Width(rest) <= width {
+			return rest
+		}
+	}
+	// Not even the ellipsis fits; the name is truncated from the right later.
+	return full
+}
+
+// cursorWidth returns the width of the wider of the two cursors.
+func (m Model) cursorWidth() int {
+	width := lipgloss.Width(m.Cursor)
+	if w := lipgloss.Width(m.DisabledCursorString); w > width {
+		width = w
+	}
+	return width
+}
+
+// cursorView returns the cursor for the selected row, padded to cursorWidth.
+func (m Model) cursorView(disabled bool) string {
+	cursor, style := m.Cursor, m.Styles.Cursor
+	if disabled {
+		style = m.Styles.DisabledCursor
+		if m.DisabledCursorString != "" {
+			cursor = m.DisabledCursorString
+		}
+	}
+	padding := strings.Repeat(" ", m.cursorWidth()-lipgloss.Width(cursor))
+	if cursor == "" {
+		return padding
+	}
+	return style.Render(cursor) + padding
+}
+
+// entryRow holds the columns of the row of an entry, before they are styled
+// for the row under the cursor or the other rows.
+type entryRow struct {
+	name string
+	// symlinkPath is the path that a symlink points to, and targetWidth the
+	// width it takes up after the name.
+	isSymlink   bool
+	symlinkPath string
+	targetWidth int
+
+	sizeText, sizeColumn     string
+	modTime, modTimeColumn   string
+	disabled, selectableDir  bool
+	indicator, count         string
+	marker, permission, icon string
+}
+
+// entryRow computes the columns of the row of f.
+func (m Model) entryRow(f os.DirEntry) entryRow {
+	r := entryRow{name: f.Name()}
+	info, _ := f.Info()
+	r.isSymlink = info.Mode()&os.ModeSymlink != 0
+	size := m.formatSize(info.Size())
+	if f.IsDir() {
+		// The size of the directory entry itself means nothing to the user.
+		size = "-"
+	}
+	if f.IsDir() && m.ComputeDirSizes {
+		size = "..."
+		if total, ok := m.dirSizes[m.join(f.Name())]; ok && total != dirSizePending {
+			size = m.formatSize(total)
+		}
+	}
+
+	// The size column has the width of the FileSize style, aligned by
+	// SizeAlign.
+	if m.ShowSize {
+		width := m.sizeWidth()
+		r.sizeText = lipgloss.NewStyle().Width(width).Align(m.SizeAlign).Render(size)
+		r.sizeColumn = m.Styles.FileSize.Width(width).Align(m.SizeAlign).Render(size)
+	}
+
+	// The modification time column has a fixed width so rows stay aligned.
+	if m.ShowModTime {
+		width := m.modTimeWidth()
+		r.modTime = fmt.Sprintf(" %*s", width, m.formatModTime(info.ModTime()))
+		r.modTimeColumn = " " + m.Styles.ModTime.Width(width).Render(m.formatModTime(info.ModTime()))
+		if !m.ShowSize {
+			r.modTime, r.modTimeColumn = r.modTime[1:], r.modTimeColumn[1:]
+		}
+	}
+
+	// If the file is a symlink, get the path that it points to.
+	if r.isSymlink && m.FileSystem == nil {
+		r.symlinkPath, _ = filepath.EvalSymlinks(filepath.Join(m.CurrentDirectory, r.name))
+	}
+	if r.isSymlink {
+		r.targetWidth = lipgloss.Width(" → " + r.symlinkPath)
+	}
+
+	// If the file is disabled, it cannot be selected.
+	// User can define which file types are allowed to be selected via the AllowedTypes field.
+	r.disabled = !f.IsDir() && !(m.FileAllowed && m.selectable(info, r.name))
+
+	// With DirAllowed, directories are marked as selectable.
+	r.selectableDir = m.DirAllowed && f.IsDir()
+
+	// The indicator of ClassifyEntries and the selectable marker go after
+	// the name, and are kept out of the truncation so they always show.
+	if m.ClassifyEntries {
+		r.indicator = classify(info.Mode())
+	}
+	if m.ShowDirCounts && f.IsDir() {
+		r.count = " (...)"
+		if n, ok := m.dirCounts[m.join(r.name)]; ok && n != dirCountPending {
+			r.count = fmt.Sprintf(" (%d)", n)
+		}
+	}
+
+	// In MultiSelect mode, marked entries get a check mark in front of them.
+	if m.MultiSelect {
+		r.marker = "  "
+		if _, ok := m.selectedFiles[m.join(r.name)]; ok {
+			r.marker = m.Styles.Marked.Render("✓") + " "
+		}
+	}
+
+	// The permission column includes its separating space so PermNone
+	// reclaims all of it.
+	r.permission = m.formatPermission(info.Mode())
+	if r.permission != "" {
+		r.permission += " "
+	}
+
+	// With ShowIcons, a glyph for the type of the entry goes in front of the name.
+	if m.ShowIcons {
+		r.icon = m.icon(r.name, info.Mode()) + " "
+	}
+	return r
+}
+
+// selectedLabel returns the columns of the row under the cursor that go in
+// front of its label and after it, and the label, which is the name followed
+// by the target of a symlink. space is the width left for the label in a list
+// of the given width.
+func (m Model) selectedLabel(r entryRow, width int) (prefix, label, suffix string, space int) {
+	prefix = fmt.Sprintf(" %s%s%s %s", r.permission, r.sizeText, r.modTime, r.icon)
+	if m.Compact {
+		prefix = " " + r.icon
+	}
+	suffix = r.indicator + r.count
+	if r.selectableDir {
+		suffix += m.Styles.SelectableDirectory.Value()
+	}
+	gutter := strings.Repeat(" ", m.cursorWidth())
+	space = width - lipgloss.Width(gutter+r.marker+prefix+suffix)
+	label = m.shownName(r.name, space-r.targetWidth)
+	if r.isSymlink {
+		label = fmt.Sprintf("%s → %s", label, r.symlinkPath)
+	}
+	return prefix, label, suffix, space
+}
+
+// listWidth returns the width of the list of entries, which ends before the
+// scrollbar.
+func (m Model) listWidth() int {
+	if m.ShowScrollbar {
+		return m.Width - scrollbarWidth
+	}
+	return m.Width
+}
+
+// scrolledLabel fits label into width runes, scrolling it by nameScroll when it
+// is too long. The scroll rests at either end for nameScrollPause steps.
+func (m Model) scrolledLabel(label string, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	runes := []rune(label)
+	overflow := lipgloss.Width(label) - width
+	if overflow <= 0 {
+		return label
+	}
+	// Leave room for the leading ellipsis once the label has scrolled.
+	overflow++
+	offset := m.nameScroll%(overflow+2*nameScrollPause) - nameScrollPause
+	if offset > overflow {
+		offset = overflow
+	}
+	if offset > len(runes) {
+		offset = len(runes)
+	}
+	if offset <= 0 {
+		return truncateName(label, width)
+	}
+	return truncateName(ellipsis+string(runes[offset:]), width)
+}
+
+// formatPermission formats the permission bits of mode according to
+// PermissionFormat.
+func (m Model) formatPermission(mode os.FileMode) string {
+	switch m.PermissionFormat {
+	case PermOctal:
+		return fmt.Sprintf("%04o", mode.Perm())
+	case PermNone:
+		return ""
+	default:
+		return mode.String()
+	}
+}
+
+// classify returns the ClassifyEntries indicator for an entry with mode.
+func classify(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "@"
+	case mode.IsDir():
+		return "/"
+	case mode&0o111 != 0:
+		return "*"
+	}
+	return ""
+}
+
+// formatSize formats a size in bytes with SI units, or with IEC units if
+// UseBinaryUnits is set.
+func (m Model) formatSize(size int64) string {
+	if m.UseBinaryUnits {
+		return humanize.IBytes(uint64(size))
+	}
+	return humanize.Bytes(uint64(size))
+}
+
+// sizeWidth returns the width of the size column, which is wide enough for the
+// longest size in the units in use, e.g. "1023 KiB".
+func (m Model) sizeWidth() int {
+	width := m.Styles.FileSize.GetWidth()
+	if w := lipgloss.Width(m.formatSize(1023 << 10)); w > width {
+		width = w
+	}
+	return width
+}
+
+// formatModTime formats a modification time using TimeFormat, or as a relative
+// time when TimeFormat is empty.
+func (m Model) formatModTime(t time.Time) string {
+	if m.TimeFormat == "" {
+		return humanize.Time(t)
+	}
+	return t.Format(m.TimeFormat)
+}
+
+// modTimeWidth returns the width of the modification time column, which is
+// wide enough for the longest relative time or for TimeFormat.
+func (m Model) modTimeWidth() int {
+	width := m.Styles.ModTime.GetWidth()
+	if m.TimeFormat != "" {
+		// Use a date with two-digit fields everywhere to get the widest output.
+		sample := time.Date(2006, time.December, 28, 23, 59, 59, 999999999, time.UTC)
+		if w := lipgloss.Width(sample.Format(m.TimeFormat)); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+// helpView returns the footer listing the key bindings, wrapped to fit the
+// width of the file picker, or an empty string when ShowHelp is false.
+func (m Model) helpView() string {
+	if !m.ShowHelp {
+		return ""
+	}
+
+	// The help of SelectMode shows which entries can be selected right now.
+	selectMode := m.KeyMap.SelectMode
+	selectMode.SetHelp(selectMode.Help().Key, selectMode.Help().Desc+": "+m.selectModeName())
+
+	bindings := []key.Binding{
+		m.KeyMap.Up, m.KeyMap.Down, m.KeyMap.Back, m.KeyMap.BackToRoot, m.KeyMap.Open, m.KeyMap.Select,
+		m.KeyMap.Filter, m.KeyMap.Sort, m.KeyMap.ToggleHidden, selectMode, m.KeyMap.Reload,
+	}
+	if m.MultiSelect {
+		bindings = append(bindings, m.KeyMap.Toggle, m.KeyMap.ConfirmSelection, m.KeyMap.SelectAll, m.KeyMap.DeselectAll, m.KeyMap.InvertSelection)
+	}
+	bindings = append(bindings,
+		m.KeyMap.Rename, m.KeyMap.Delete, m.KeyMap.MkDir, m.KeyMap.EnterPath, m.KeyMap.CopyPath, m.KeyMap.Yank, m.KeyMap.Cut, m.KeyMap.Paste, m.KeyMap.OpenExternal, m.KeyMap.Inspect, m.KeyMap.Undo, m.KeyMap.SetBookmark, m.KeyMap.JumpBookmark, m.KeyMap.JumpLetter,
+		m.KeyMap.Help, m.KeyMap.Quit,
+	)
+
+	const separator = " • "
+	width := m.Width - m.Styles.Help.GetHorizontalFrameSize()
+	var lines []string
+	var line string
+	for _, b := range bindings {
+		if !b.Enabled() {
+			continue
+		}
+		item := b.Help().Key + " " + b.Help().Desc
+		switch {
+		case line == "":
+			line = item
+		case width > 0 && lipgloss.Width(line+separator+item) > width:
+			lines = append(lines, line)
+			line = item
+		default:
+			line += separator + item
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return "\n" + m.Styles.Help.Render(strings.Join(lines, "\n")) + "\n"
+}
+
+// scrollIndicatorView returns the range of entries in view, or nothing if
+// ShowScrollIndicator is off or all entries are visible.
+func (m Model) scrollIndicatorView() string {
+	if !m.ShowScrollIndicator || len(m.files) == 0 {
+		return ""
+	}
+	// min and max may point past the listing, so clamp them to what View
+	// actually renders.
+	first, last := m.min, m.max
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(m.files) {
+		last = len(m.files) - 1
+	}
+	if first == 0 && last == len(m.files)-1 {
+		return ""
+	}
+	return m.Styles.ScrollIndicator.Render(fmt.Sprintf(m.Messages.ScrollIndicator, first+1, last+1, len(m.files))) + "\n"
+}
+
+// scrollbarView returns a scrollbar of the given number of rows. The thumb is
+// as long, relative to the scrollbar, as the part of the entries in view is to
+// all of them, so it fills the scrollbar when everything fits.
+func (m Model) scrollbarView(rows int) string {
+	total := len(m.files)
+	if rows <= 0 || total == 0 {
+		return ""
+	}
+	thumb := rows * rows / total
+	if thumb < 1 {
+		thumb = 1
+	}
+	if thumb > rows {
+		thumb = rows
+	}
+	start := m.min * rows / total
+	if start+thumb > rows || m.min+rows >= total {
+		// Keep the thumb at the bottom once the last entry is in view.
+		start = rows - thumb
+	}
+
+	bar := make([]string, rows)
+	for i := range bar {
+		bar[i] = "░"
+		if i >= start && i < start+thumb {
+			bar[i] = "█"
+		}
+	}
+	return m.Styles.Scrollbar.Render(strings.Join(bar, "\n"))
+}
+
 // SetHeight sets the height of the file picker. If AutoHeight is true, this
 // will set AutoHeight to false.
 func (m *Model) SetHeight(height int) {
@@ -530,24 +2846,159 @@ func (m *Model) SetWidth(width int) {
 	m.Width = width
 }
 
-// DidSelectFile returns whether a user has selected a file (on this msg).
+// OnSelect registers fn to be called with the full path whenever the user
+// selects an entry.
+func (m *Model) OnSelect(fn func(path string)) {
+	m.onSelect = fn
+}
+
+// OnError registers fn to be called whenever reading a directory or a file
+// operation fails, e.g. when a directory can't be opened for lack of
+// permissions.
+func (m *Model) OnError(fn func(err error)) {
+	m.onError = fn
+}
+
+// OnDirChange registers fn to be called with the new directory whenever the
+// user navigates to another directory.
+func (m *Model) OnDirChange(fn func(dir string)) {
+	m.onDirChange = fn
+}
+
+// notifyDirChange calls the OnDirChange callback if the current directory is
+// no longer prev.
+func (m Model) notifyDirChange(prev string) {
+	if m.onDirChange != nil && m.CurrentDirectory != prev {
+		m.onDirChange(m.CurrentDirectory)
+	}
+}
+
+// OnHighlight registers fn to be called with the full path of the entry under
+// the cursor whenever the cursor moves to another entry.
+func (m *Model) OnHighlight(fn func(path string)) {
+	m.onHighlight = fn
+}
+
+// notifyHighlight calls the OnHighlight callback if the cursor is no longer on
+// the entry at index prev.
+func (m Model) notifyHighlight(prev int) {
+	if m.onHighlight != nil && m.selected != prev && len(m.files) > 0 {
+		m.onHighlight(m.HighlightedFile())
+	}
+}
+
+// HighlightedFile returns the full path of the entry under the cursor, or an
+// empty string if the directory is empty.
+func (m Model) HighlightedFile() string {
+	if m.selected < 0 || m.selected >= len(m.files) {
+		return ""
+	}
+	return m.join(m.files[m.selected].Name())
+}
+
+// SelectedFile returns the path the user selected with the file picker, which
+// is empty if nothing has been selected yet. The error is non-nil if the most
+// recent selection attempt failed to stat the entry or resolve its symlink.
+func (m Model) SelectedFile() (string, error) {
+	return m.Path, m.lastErr
+}
+
+// SelectedFiles returns the full paths of the entries marked in MultiSelect
+// mode, in the order they were marked.
+func (m Model) SelectedFiles() []string {
+	files := make([]string, len(m.selectionOrder))
+	copy(files, m.selectionOrder)
+	return files
+}
+
+// SelectedInfo returns the file info of the entry under the cursor, or
+// ErrEmptyDirectory if the current directory has no entries.
+func (m Model) SelectedInfo() (os.FileInfo, error) {
+	if m.selected < 0 || m.selected >= len(m.files) {
+		return nil, ErrEmptyDirectory
+	}
+	return m.files[m.selected].Info()
+}
+
+// isSelectKey reports whether the key press selects the entry under the
+// cursor, which includes enter when SelectOnEnter is set.
+func (m Model) isSelectKey(msg tea.KeyMsg) bool {
+	return key.Matches(msg, m.KeyMap.Select) || (m.SelectOnEnter && msg.Type == tea.KeyEnter)
+}
+
+// isConfirmKey reports whether the key press ends a MultiSelect selection:
+// the ConfirmSelection key, or enter when SelectOnEnter is set. The Select and
+// Toggle keys only mark entries.
+func (m Model) isConfirmKey(msg tea.KeyMsg) bool {
+	return key.Matches(msg, m.KeyMap.ConfirmSelection) || (m.SelectOnEnter && msg.Type == tea.KeyEnter)
+}
+
+// DidSelectFile returns whether a user has selected a file (on this msg). In
+// MultiSelect mode only ending the selection counts, and it returns the first
+// marked path. Use DidSelectFiles to get all of them.
 func (m Model) DidSelectFile(msg tea.Msg) (bool, string) {
+	if m.MultiSelect {
+		didSelect, paths := m.DidSelectFiles(msg)
+		if !didSelect {
+			return false, ""
+		}
+		return true, paths[0]
+	}
 	didSelect, path := m.didSelectFile(msg)
-	if didSelect && m.canSelect(path) {
+	if didSelect && m.canSelect(m.files[m.selected]) {
 		return true, path
 	}
 	return false, ""
 }
 
+// DidSelectFiles returns whether a user has ended a MultiSelect selection (on
+// this msg) and the marked paths in the order they were marked. Outside of
+// MultiSelect mode, it returns the path DidSelectFile does.
+func (m Model) DidSelectFiles(msg tea.Msg) (bool, []string) {
+	if !m.MultiSelect {
+		didSelect, path := m.DidSelectFile(msg)
+		if !didSelect {
+			return false, nil
+		}
+		return true, []string{path}
+	}
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || !m.isConfirmKey(keyMsg) || len(m.selectionOrder) == 0 {
+		return false, nil
+	}
+	return true, m.SelectedFiles()
+}
+
+// DidSelectDirectory returns whether a user has selected a directory (on this
+// msg), which requires DirAllowed. Symlinks to directories count as
+// directories.
+func (m Model) DidSelectDirectory(msg tea.Msg) (bool, string) {
+	didSelect, path := m.DidSelectFile(msg)
+	if !didSelect {
+		return false, ""
+	}
+	if isDir, err := m.resolveDir(m.files[m.selected]); err != nil || !isDir {
+		return false, ""
+	}
+	return true, path
+}
+
 // DidSelectDisabledFile returns whether a user tried to select a disabled file
 // (on this msg). This is necessary only if you would like to warn the user that
 // they tried to select a disabled file.
 func (m Model) DidSelectDisabledFile(msg tea.Msg) (bool, string) {
-	didSelect, path := m.didSelectFile(msg)
-	if didSelect && !m.canSelect(path) {
-		return true, path
+	// A disabled file is never stored in m.Path, so look at the entry under
+	// the cursor instead of going through didSelectFile.
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || !m.isSelectKey(keyMsg) || len(m.files) == 0 {
+		return false, ""
 	}
-	return false, ""
+	f := m.files[m.selected]
+	isDir, err := m.resolveDir(f)
+	if err != nil || isDir || !m.FileAllowed || m.canSelect(f) {
+		return false, ""
+	}
+	return true, m.join(f.Name())
 }
 
 func (m Model) didSelectFile(msg tea.Msg) (bool, string) {
@@ -557,7 +3008,7 @@ func (m Model) didSelectFile(msg tea.Msg) (bool, string) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		// If the msg does not match the Select keymap then this could not have been a selection.
-		if !key.Matches(msg, m.KeyMap.Select) {
+		if !m.isSelectKey(msg) {
 			return false, ""
 		}
 
@@ -571,9 +3022,8 @@ func (m Model) didSelectFile(msg tea.Msg) (bool, string) {
 		isSymlink := info.Mode()&os.ModeSymlink != 0
 		isDir := f.IsDir()
 
-		if isSymlink {
-			symlinkPath, _ := filepath.EvalSymlinks(filepath.Join(m.CurrentDirectory, f.Name()))
-			info, err := os.Stat(symlinkPath)
+		if isSymlink && m.FollowSymlinks {
+			info, err := m.stat(m.join(f.Name()))
 			if err != nil {
 				break
 			}
@@ -582,7 +3032,7 @@ func (m Model) didSelectFile(msg tea.Msg) (bool, string) {
 			}
 		}
 
-		if (!isDir && m.FileAllowed) || (isDir && m.DirAllowed) && m.Path != "" {
+		if ((!isDir && m.FileAllowed) || (isDir && m.DirAllowed)) && m.Path != "" {
 			return true, m.Path
 		}
 
@@ -594,15 +3044,97 @@ func (m Model) didSelectFile(msg tea.Msg) (bool, string) {
 	return false, ""
 }
 
-func (m Model) canSelect(file string) bool {
+// cycleSelectMode switches between selecting only files, only directories
+// and both by changing FileAllowed and DirAllowed.
+func (m *Model) cycleSelectMode() {
+	switch {
+	case m.FileAllowed && !m.DirAllowed:
+		m.FileAllowed, m.DirAllowed = false, true
+	case !m.FileAllowed && m.DirAllowed:
+		m.FileAllowed, m.DirAllowed = true, true
+	default:
+		m.FileAllowed, m.DirAllowed = true, false
+	}
+}
+
+// selectModeName describes which entries FileAllowed and DirAllowed let the
+// user select.
+func (m Model) selectModeName() string {
+	switch {
+	case m.FileAllowed && m.DirAllowed:
+		return m.Messages.SelectBoth
+	case m.DirAllowed:
+		return m.Messages.SelectDirs
+	case m.FileAllowed:
+		return m.Messages.SelectFiles
+	}
+	return m.Messages.SelectNone
+}
+
+// canSelect reports whether the entry can be selected: a directory if
+// DirAllowed is set, and a file if FileAllowed is set and it passes the
+// selection constraints. See selectable.
+func (m Model) canSelect(f os.DirEntry) bool {
+	isDir, err := m.resolveDir(f)
+	if err != nil {
+		return false
+	}
+	if isDir {
+		return m.DirAllowed
+	}
+	info, _ := f.Info()
+	return m.FileAllowed && m.selectable(info, f.Name())
+}
+
+// selectable reports whether an entry called name passes the MinFileSize,
+// MaxFileSize, ModeFilter and AllowedTypes constraints. The size and mode
+// constraints only apply to files and are skipped when info is nil. Both the
+// view and the selection go through here so they can't disagree about what is
+// disabled.
+func (m Model) selectable(info os.FileInfo, name string) bool {
+	if info != nil && !info.IsDir() {
+		if m.MaxFileSize > 0 && info.Size() > m.MaxFileSize {
+			return false
+		}
+		if m.MinFileSize > 0 && info.Size() < m.MinFileSize {
+			return false
+		}
+		if m.ModeFilter != 0 && info.Mode()&m.ModeFilter == 0 {
+			return false
+		}
+	}
+
 	if len(m.AllowedTypes) <= 0 {
 		return true
 	}
+	return m.matchesAny(name, m.AllowedTypes)
+}
 
-	for _, ext := range m.AllowedTypes {
-		if strings.HasSuffix(file, ext) {
+// matchesAny reports whether name matches any of the patterns, ignoring case
+// unless CaseSensitiveTypes is set. See matchesType.
+func (m Model) matchesAny(name string, patterns []string) bool {
+	if !m.CaseSensitiveTypes {
+		name = strings.ToLower(name)
+	}
+	for _, pattern := range patterns {
+		if !m.CaseSensitiveTypes {
+			pattern = strings.ToLower(pattern)
+		}
+		if matchesType(name, pattern) {
 			return true
 		}
 	}
 	return false
 }
+
+// matchesType reports whether file matches an AllowedTypes entry. Entries
+// containing glob metacharacters, like "*.tar.gz" or "report-??.csv", are
+// matched against the base name with filepath.Match. Any other entry, like
+// ".png", matches files whose name ends with it.
+func matchesType(file, pattern string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, _ := filepath.Match(pattern, filepath.Base(file))
+		return matched
+	}
+	return strings.HasSuffix(file, pattern)
+}
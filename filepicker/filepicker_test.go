@@ -0,0 +1,1455 @@
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTree creates the given files in a temporary directory and returns it.
+// Names ending in a slash are created as directories.
+func makeTree(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			require.NoError(t, os.MkdirAll(path, 0o755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+	}
+	return dir
+}
+
+// newTestModel returns a picker showing dir, configured by opts, with the
+// directory already read.
+func newTestModel(t *testing.T, dir string, opts ...Option) Model {
+	t.Helper()
+	m := NewWithOptions(append([]Option{WithPath(dir), WithHeight(10)}, opts...)...)
+	return run(m, m.readDirCmd())
+}
+
+// run executes cmd and feeds the messages it returns back into the model,
+// following batches, until no command is left. Commands that would block,
+// like ticks, aren't run because the tests send the messages they care about
+// themselves.
+func run(m Model, cmd tea.Cmd) Model {
+	for cmd != nil {
+		switch msg := cmd().(type) {
+		case nil, tea.QuitMsg, nameScrollMsg:
+			// The ticks that scroll the selected name would go on forever.
+			return m
+		case tea.BatchMsg:
+			for _, c := range msg {
+				m = run(m, c)
+			}
+			return m
+		default:
+			m, cmd = m.Update(msg)
+		}
+	}
+	return m
+}
+
+// press sends the keys to the model one after another and runs the commands
+// they return.
+func press(m Model, keys ...string) Model {
+	for _, k := range keys {
+		var cmd tea.Cmd
+		m, cmd = m.Update(keyMsg(k))
+		m = run(m, cmd)
+	}
+	return m
+}
+
+func keyMsg(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case "pgdown":
+		return tea.KeyMsg{Type: tea.KeyPgDown}
+	case "pgup":
+		return tea.KeyMsg{Type: tea.KeyPgUp}
+	case "ctrl+a":
+		return tea.KeyMsg{Type: tea.KeyCtrlA}
+	case "ctrl+d":
+		return tea.KeyMsg{Type: tea.KeyCtrlD}
+	case "ctrl+r":
+		return tea.KeyMsg{Type: tea.KeyCtrlR}
+	case "ctrl+x":
+		return tea.KeyMsg{Type: tea.KeyCtrlX}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// numbered returns the names f00, f01 and so on of n files.
+func numbered(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("f%02d", i)
+	}
+	return names
+}
+
+// names returns the names of the listed entries.
+func names(m Model) []string {
+	names := make([]string, len(m.files))
+	for i, f := range m.files {
+		names[i] = f.Name()
+	}
+	return names
+}
+
+func TestNewGivesUniqueIDs(t *testing.T) {
+	ResetIDs()
+	const n = 100
+	ids := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- New().id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "id %d given twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, n)
+
+	ResetIDs()
+	assert.Equal(t, 1, New().id)
+}
+
+func TestMultiSelectEnterConfirmsMarkedEntries(t *testing.T) {
+	dir := makeTree(t, "a", "b", "c")
+	m := newTestModel(t, dir)
+	m.MultiSelect = true
+	m.SelectOnEnter = true
+
+	m = press(m, " ", "down", " ", "down")
+	require.Equal(t, "c", m.files[m.selected].Name())
+	m, cmd := m.Update(keyMsg("enter"))
+
+	require.NotNil(t, cmd)
+	assert.IsType(t, tea.QuitMsg{}, cmd())
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, m.SelectedFiles())
+	assert.Empty(t, m.Path)
+}
+
+func TestMultiSelectTogglesUntilConfirmed(t *testing.T) {
+	dir := makeTree(t, "a", "b", "c")
+	m := newTestModel(t, dir)
+	m.MultiSelect = true
+
+	for _, k := range []string{" ", "down", " "} {
+		var cmd tea.Cmd
+		m, cmd = m.Update(keyMsg(k))
+		assert.Nil(t, cmd, k)
+		didSelect, _ := m.DidSelectFile(keyMsg(k))
+		assert.False(t, didSelect, k)
+		didSelect, _ = m.DidSelectFiles(keyMsg(k))
+		assert.False(t, didSelect, k)
+	}
+
+	m, cmd := m.Update(keyMsg("ctrl+d"))
+	require.NotNil(t, cmd)
+	assert.IsType(t, tea.QuitMsg{}, cmd())
+	want := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	didSelect, path := m.DidSelectFile(keyMsg("ctrl+d"))
+	assert.True(t, didSelect)
+	assert.Equal(t, want[0], path)
+	didSelect, paths := m.DidSelectFiles(keyMsg("ctrl+d"))
+	assert.True(t, didSelect)
+	assert.Equal(t, want, paths)
+}
+
+func TestSelectionBadge(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "a", "b", "c"))
+	m.MultiSelect = true
+	// The path box is narrow enough to wrap the badge.
+	m.ShowPathBox = false
+	assert.NotContains(t, m.headerView(), "selected")
+
+	m = press(m, " ", "down", " ")
+	assert.Contains(t, m.headerView(), "(2 selected)")
+
+	m.MultiSelect = false
+	assert.NotContains(t, m.headerView(), "selected")
+}
+
+func TestSelectAllRespectsFilter(t *testing.T) {
+	dir := makeTree(t, "apple.txt", "avocado.bin", "banana.txt", "cherry.txt")
+	m := newTestModel(t, dir, WithAllowedTypes(".txt"))
+	m.MultiSelect = true
+
+	m = press(m, "/", "a", "enter")
+	require.Equal(t, []string{"apple.txt", "avocado.bin", "banana.txt"}, names(m))
+	m = press(m, "ctrl+a")
+	// avocado.bin is listed but can't be selected.
+	assert.Equal(t, []string{filepath.Join(dir, "apple.txt"), filepath.Join(dir, "banana.txt")}, m.SelectedFiles())
+
+	m = press(m, "ctrl+x")
+	assert.Empty(t, m.SelectedFiles())
+}
+
+func TestInvertSelection(t *testing.T) {
+	dir := makeTree(t, "a", "b", "c", "d", "e")
+	m := newTestModel(t, dir)
+	m.MultiSelect = true
+
+	m = press(m, " ", "down", "down", " ", "*")
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "b"), filepath.Join(dir, "d"), filepath.Join(dir, "e")}, m.SelectedFiles())
+}
+
+func TestViewFitsWindowWidth(t *testing.T) {
+	long := "a-file-with-a-rather-long-name-that-needs-truncating.txt"
+	dir := makeTree(t, "sub/", "a.txt", long)
+	for _, width := range []int{30, 40, 120} {
+		m := newTestModel(t, dir, func(m *Model) { m.TruncateNames = true })
+		m, _ = m.Update(tea.WindowSizeMsg{Width: width, Height: 20})
+		lines := strings.Split(m.View(), "\n")
+		for _, line := range lines {
+			assert.LessOrEqual(t, lipgloss.Width(line), width, "%d: %q", width, line)
+		}
+		// The dialog and the truncated name take the whole width.
+		assert.Equal(t, width, lipgloss.Width(lines[0]), width)
+		if lipgloss.Width(long) > width {
+			row := listing(m)[1]
+			require.Contains(t, row, ellipsis)
+			assert.Equal(t, width, lipgloss.Width(row), width)
+		}
+	}
+}
+
+func TestResizeAfterScrollKeepsCursorVisible(t *testing.T) {
+	m := newTestModel(t, makeTree(t, numbered(30)...))
+	m.AutoHeight = true
+	m = press(m, "G")
+
+	for _, height := range []int{15, 10, 25} {
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: height})
+		require.Equal(t, height-defaultMarginBottom, m.Height)
+		assert.LessOrEqual(t, m.min, m.selected)
+		assert.LessOrEqual(t, m.selected, m.max)
+		assert.Equal(t, m.Height-1, m.max-m.min)
+		assert.Contains(t, m.View(), "f29")
+	}
+}
+
+func TestNameScrollOnlyTicksWhileNameOverflows(t *testing.T) {
+	dir := makeTree(t, "a-name-that-is-far-too-long-for-the-row-it-is-shown-in.txt", "b.txt")
+	m := newTestModel(t, dir)
+	require.False(t, m.nameScrolling)
+	m, cmd := m.Update(tea.WindowSizeMsg{Width: 44, Height: 20})
+	require.True(t, m.nameScrolling)
+	require.NotNil(t, cmd)
+	// A tick is already on its way, so no other one is started.
+	_, cmd = m.Update(keyMsg("j"))
+	assert.Nil(t, cmd)
+
+	m, cmd = m.Update(nameScrollMsg{id: m.id})
+	assert.NotNil(t, cmd)
+	assert.True(t, m.nameScrolling)
+
+	// Once the cursor is on a name that fits, the next tick is the last.
+	m, _ = m.Update(keyMsg("down"))
+	m, cmd = m.Update(nameScrollMsg{id: m.id})
+	assert.Nil(t, cmd)
+	assert.False(t, m.nameScrolling)
+
+	// Ticks of other pickers are ignored.
+	m, cmd = m.Update(nameScrollMsg{id: m.id + 1})
+	assert.Nil(t, cmd)
+}
+
+func TestNameScrollDoesNotTickWhenNamesFit(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "a.txt", "b.txt"), WithWidth(80))
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 84, Height: 20})
+	m, _ = m.Update(keyMsg("down"))
+	assert.False(t, m.nameScrolling)
+}
+
+func TestUndoRename(t *testing.T) {
+	dir := makeTree(t, "a.txt", "c.txt")
+	m := newTestModel(t, dir)
+	// With nothing to undo, the key does nothing.
+	m = press(m, "u")
+	assert.Empty(t, m.notice)
+
+	m = run(m, m.rename("a.txt", "b.txt"))
+	require.Equal(t, []string{"b.txt", "c.txt"}, names(m))
+	m = press(m, "u")
+	assert.Equal(t, []string{"a.txt", "c.txt"}, names(m))
+	assert.NoFileExists(t, filepath.Join(dir, "b.txt"))
+	assert.Equal(t, "undid renaming a.txt to b.txt", m.notice)
+	assert.Contains(t, m.View(), m.notice)
+	assert.Equal(t, "a.txt", m.files[m.selected].Name())
+
+	// Entries deleted for good can't be brought back.
+	m = press(m, "d", "y")
+	require.NoFileExists(t, filepath.Join(dir, "a.txt"))
+	assert.Empty(t, m.undoStack)
+	m = press(m, "u")
+	assert.Equal(t, []string{"c.txt"}, names(m))
+}
+
+func TestPasteIntoAnotherDirectory(t *testing.T) {
+	root := makeTree(t, "src/a.txt", "src/b.txt", "dst/a.txt")
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	open := func(m Model, dir string) Model {
+		require.NoError(t, m.SetCurrentDirectory(dir))
+		return run(m, m.readDirCmd())
+	}
+	content := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		m := newTestModel(t, src)
+		m.MultiSelect = true
+		m = press(m, " ", "down", " ", "y")
+		assert.Contains(t, m.View(), "yanked: a.txt, b.txt")
+
+		m = press(open(m, dst), "p")
+		// The a.txt that was there keeps its name and content.
+		assert.Equal(t, []string{"a-1.txt", "a.txt", "b.txt"}, names(m))
+		assert.Equal(t, "src/a.txt", content("a-1.txt"))
+		assert.Equal(t, "dst/a.txt", content("a.txt"))
+		assert.FileExists(t, filepath.Join(src, "a.txt"))
+
+		// Copied entries stay on the clipboard.
+		m = press(m, "p")
+		assert.Equal(t, []string{"a-1.txt", "a-2.txt", "a.txt", "b-1.txt", "b.txt"}, names(m))
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		m := newTestModel(t, src)
+		m = press(m, "x")
+		assert.Contains(t, m.View(), "cut: a.txt")
+
+		// Moving asks first. The copies above took a-1.txt and a-2.txt.
+		m = press(open(m, dst), "p", "y")
+		assert.Contains(t, names(m), "a-3.txt")
+		assert.Equal(t, "src/a.txt", content("a-3.txt"))
+		assert.NoFileExists(t, filepath.Join(src, "a.txt"))
+		assert.NotContains(t, m.View(), "cut:")
+	})
+}
+
+func TestDeleteMarkedGoesOnAfterFailure(t *testing.T) {
+	dir := makeTree(t, "a", "b", "c", "d", "e", "f")
+	m := newTestModel(t, dir)
+	m.MultiSelect = true
+
+	// b is gone by the time it is deleted, which fails.
+	m = press(m, " ", "down", " ", "down", " ", "d")
+	require.NoError(t, os.Remove(filepath.Join(dir, "b")))
+	m = press(m, "y")
+	assert.Equal(t, []string{"d", "e", "f"}, names(m))
+	require.Error(t, m.err)
+	assert.ErrorIs(t, m.err, os.ErrNotExist)
+	assert.Contains(t, m.err.Error(), filepath.Join(dir, "b"))
+	assert.Empty(t, m.SelectedFiles())
+
+	// The errors of several entries are reported together.
+	m = press(m, "g", " ", "down", " ", "down", " ", "d")
+	require.NoError(t, os.Remove(filepath.Join(dir, "d")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "e")))
+	m = press(m, "y")
+	assert.Empty(t, names(m))
+	require.Error(t, m.err)
+	assert.Contains(t, m.err.Error(), "2 entries failed: ")
+	assert.Contains(t, m.err.Error(), filepath.Join(dir, "d"))
+	assert.Contains(t, m.err.Error(), filepath.Join(dir, "e"))
+}
+
+func TestConfirmThreshold(t *testing.T) {
+	tree := []string{"dst/"}
+	for _, name := range numbered(12) {
+		tree = append(tree, "src/"+name)
+	}
+	root := makeTree(t, tree...)
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	m := newTestModel(t, src, func(m *Model) { m.ConfirmThreshold = 5 })
+	m.MultiSelect = true
+	mark := func(m Model, n int) Model {
+		m = press(m, "g")
+		for i := 0; i < n; i++ {
+			m = press(m, " ", "down")
+		}
+		return m
+	}
+
+	// Below the threshold, the entries are deleted right away.
+	m = press(mark(m, 3), "d")
+	assert.Equal(t, noConfirm, m.confirming)
+	assert.Len(t, m.files, 9)
+	assert.NoFileExists(t, filepath.Join(src, "f00"))
+
+	m = press(mark(m, 6), "d")
+	require.Equal(t, confirmDelete, m.confirming)
+	assert.Contains(t, m.View(), "Delete 6 entries (f03, f04, f05, …)? (y/n)")
+	m = press(m, "n")
+	assert.Len(t, m.files, 9)
+
+	// Moving as many entries as the threshold asks too.
+	m = press(m, "ctrl+x")
+	m = press(mark(m, 5), "x")
+	require.NoError(t, m.SetCurrentDirectory(dst))
+	m = press(run(m, m.readDirCmd()), "p")
+	require.Equal(t, confirmMove, m.confirming)
+	assert.Contains(t, m.View(), "Move 5 entries (f03, f04, f05, …) here? (y/n)")
+	m = press(m, "y")
+	assert.Equal(t, []string{"f03", "f04", "f05", "f06", "f07"}, names(m))
+}
+
+func TestLoadingUntilReadArrives(t *testing.T) {
+	dir := makeTree(t, "sub/x", "a")
+	m := newTestModel(t, dir)
+	require.False(t, m.loading)
+
+	// The read of sub isn't run, as if it were slow.
+	m, cmd := m.Update(keyMsg("l"))
+	require.NotNil(t, cmd)
+	require.Equal(t, filepath.Join(dir, "sub"), m.CurrentDirectory)
+	assert.True(t, m.loading)
+	assert.Contains(t, m.View(), m.spinner.View()+" "+DefaultMessages.Loading)
+	m, cmd = m.Update(spinner.TickMsg{ID: m.spinner.ID()})
+	assert.NotNil(t, cmd, "the spinner spins while loading")
+
+	// The result of an older read is dropped and doesn't end the loading.
+	m, _ = m.Update(readDirMsg{gen: m.readGen - 1})
+	assert.True(t, m.loading)
+	assert.Contains(t, m.View(), DefaultMessages.Loading)
+
+	msg := m.readDirCmd()()
+	require.IsType(t, readDirMsg{}, msg)
+	m, _ = m.Update(msg)
+	assert.False(t, m.loading)
+	assert.NotContains(t, m.View(), DefaultMessages.Loading)
+	assert.Equal(t, []string{"x"}, names(m))
+	_, cmd = m.Update(spinner.TickMsg{ID: m.spinner.ID()})
+	assert.Nil(t, cmd)
+}
+
+func TestFailedOperationsStopLoading(t *testing.T) {
+	dir := makeTree(t, "a.txt", "b.txt")
+	for name, op := range map[string]func(m *Model) tea.Cmd{
+		"rename onto existing": func(m *Model) tea.Cmd { return m.rename("a.txt", "b.txt") },
+		"rename to bad name":   func(m *Model) tea.Cmd { return m.rename("a.txt", "x/y") },
+		"mkdir existing":       func(m *Model) tea.Cmd { return m.mkdir("a.txt") },
+	} {
+		m := newTestModel(t, dir)
+		cmd := op(&m)
+		require.True(t, m.loading, name)
+		m = run(m, cmd)
+		assert.False(t, m.loading, name)
+		assert.Error(t, m.err, name)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, names(m), name)
+	}
+}
+
+func TestGroupHeadersCountAgainstHeight(t *testing.T) {
+	dir := makeTree(t, "d1/", "d2/", "a.png", "b.png", "c.png", "x.txt", "y.txt", "z.bin")
+	m := NewWithOptions(WithPath(dir), WithHeight(4))
+	m.GroupByType = true
+	m = run(m, m.readDirCmd())
+	want := []string{"d1", "d2", "a.png", "b.png", "c.png", "x.txt", "y.txt", "z.bin"}
+	require.Equal(t, want, names(m))
+
+	// rows counts the lines of the listing: the entries and group headers.
+	rows := func(view string) int {
+		labels := append([]string{"Directories", "Images", "Documents", "Other"}, want...)
+		n := 0
+		for _, line := range strings.Split(strings.TrimPrefix(view, m.headerView()), "\n") {
+			for _, label := range labels {
+				if strings.Contains(line, label) {
+					n++
+					break
+				}
+			}
+		}
+		return n
+	}
+	for i, name := range want {
+		require.Equal(t, i, m.selected)
+		view := m.View()
+		assert.Contains(t, view, name)
+		assert.LessOrEqual(t, rows(view), m.Height, name)
+		assert.LessOrEqual(t, m.min, m.selected, name)
+		assert.LessOrEqual(t, m.selected, m.max, name)
+		m = press(m, "down")
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		m = press(m, "up")
+		if i > 0 {
+			require.Equal(t, i-1, m.selected)
+		}
+		assert.LessOrEqual(t, rows(m.View()), m.Height)
+	}
+
+	// Filtering doesn't re-read the directory, but the headers still count.
+	m = press(m, "/", ".")
+	require.Equal(t, []string{"a.png", "b.png", "c.png", "x.txt", "y.txt", "z.bin"}, names(m))
+	assert.LessOrEqual(t, rows(m.View()), m.Height)
+}
+
+func TestRecursiveListing(t *testing.T) {
+	dir := makeTree(t, "a.txt", "sub/b.txt", "sub/deep/c.txt", "sub/deep/deeper/d.txt",
+		"sub/.e.txt", ".hidden/f.txt", "empty/")
+	recursive := func(m *Model) { m.Recursive, m.RecursiveDepth = true, 3 }
+	m := newTestModel(t, dir, recursive)
+
+	// Files are listed by their path below dir, three levels deep at most,
+	// without hidden ones and without directories.
+	c := filepath.Join("sub", "deep", "c.txt")
+	assert.Equal(t, []string{"a.txt", filepath.Join("sub", "b.txt"), c}, names(m))
+	assert.Contains(t, m.View(), c)
+	assert.NotContains(t, m.View(), fmt.Sprintf(DefaultMessages.TreeTruncated, maxTreeEntries))
+
+	m = press(m, "G")
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, path := m.DidSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, c), path)
+
+	if runtime.GOOS != "windows" {
+		m = newTestModel(t, dir, recursive, WithShowHidden(true))
+		assert.Equal(t, []string{filepath.Join(".hidden", "f.txt"), "a.txt", filepath.Join("sub", ".e.txt"),
+			filepath.Join("sub", "b.txt"), c}, names(m))
+	}
+}
+
+func TestRecursiveListingIsCapped(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i <= maxTreeEntries; i++ {
+		fsys[fmt.Sprintf("d%d/f%05d", i%10, i)] = &fstest.MapFile{}
+	}
+	m := NewWithOptions(WithHeight(10), func(m *Model) {
+		m.FileSystem = fsys
+		m.Recursive = true
+	})
+	m = run(m, m.Init())
+	assert.Len(t, m.files, maxTreeEntries)
+	assert.Contains(t, m.View(), fmt.Sprintf(DefaultMessages.TreeTruncated, maxTreeEntries))
+
+	// The notice goes once all the files fit.
+	delete(fsys, "d0/f00000")
+	m = press(m, "ctrl+r")
+	assert.Len(t, m.files, maxTreeEntries)
+	assert.NotContains(t, m.View(), fmt.Sprintf(DefaultMessages.TreeTruncated, maxTreeEntries))
+}
+
+func TestRecursiveRenameStaysInDirectory(t *testing.T) {
+	dir := makeTree(t, "sub/file.txt", "top.txt")
+	m := newTestModel(t, dir)
+	m.Recursive = true
+	m = run(m, m.readDirCmd())
+	name := filepath.Join("sub", "file.txt")
+	require.Contains(t, names(m), name)
+	for m.files[m.selected].Name() != name {
+		m = press(m, "down")
+	}
+
+	// The input's cursor would blink forever, so its command isn't run.
+	m, _ = m.Update(keyMsg("r"))
+	require.True(t, m.renaming)
+	assert.Equal(t, "file.txt", m.renameInput.Value())
+	m.renameInput.SetValue("renamed.txt")
+	m = press(m, "enter")
+
+	require.NoError(t, m.err)
+	assert.FileExists(t, filepath.Join(dir, "sub", "renamed.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, "sub", "file.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, "renamed.txt"))
+}
+
+func TestModeFilterDisablesFilesWithoutModeBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("files have no executable bits on Windows")
+	}
+	dir := makeTree(t, "plain", "tool")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "tool"), 0o755))
+	m := newTestModel(t, dir)
+	m.ModeFilter = 0o111
+
+	require.Equal(t, []string{"plain", "tool"}, names(m))
+	assert.False(t, m.canSelect(m.files[0]))
+	assert.True(t, m.entryRow(m.files[0]).disabled)
+	assert.True(t, m.canSelect(m.files[1]))
+	assert.False(t, m.entryRow(m.files[1]).disabled)
+
+	m.ModeFilter = 0
+	assert.True(t, m.canSelect(m.files[0]))
+}
+
+func TestViewUsesMessages(t *testing.T) {
+	french := DefaultMessages
+	french.EmptyDirectory = "Aucun fichier."
+	french.AlreadyExists = "%s existe déjà"
+	french.BatchFailed = "%d entrées ont échoué : %s"
+
+	m := newTestModel(t, makeTree(t))
+	m.Messages = french
+	assert.Contains(t, m.View(), "Aucun fichier.")
+
+	dir := makeTree(t, "a.txt", "b.txt")
+	m = newTestModel(t, dir)
+	m.Messages = french
+	m = run(m, m.rename("a.txt", "b.txt"))
+	assert.Contains(t, m.View(), "b.txt existe déjà")
+
+	err := joinErrors([]error{os.ErrExist, os.ErrNotExist}, french)
+	assert.EqualError(t, err, "2 entrées ont échoué : "+os.ErrExist.Error()+"; "+os.ErrNotExist.Error())
+}
+
+func TestJumpToLetterCyclesThroughMatches(t *testing.T) {
+	dir := makeTree(t, "Apple", "banana", "avocado", "apricot", "cherry", "date", "dill")
+	m := newTestModel(t, dir)
+	require.Equal(t, []string{"Apple", "apricot", "avocado", "banana", "cherry", "date", "dill"}, names(m))
+
+	selected := func() string { return m.files[m.selected].Name() }
+	m = press(m, "c")
+	assert.Equal(t, "cherry", selected())
+	// Matching ignores case, and wraps around past the last entry.
+	m = press(m, "A")
+	assert.Equal(t, "Apple", selected())
+	m = press(m, "a")
+	assert.Equal(t, "apricot", selected())
+	m = press(m, "a", "a")
+	assert.Equal(t, "Apple", selected())
+
+	// d is bound to Delete, so it is only reached through JumpLetter.
+	m = press(m, "f", "d")
+	assert.Equal(t, "date", selected())
+	m = press(m, "f", "d", "f", "d")
+	assert.Equal(t, "date", selected())
+	assert.False(t, m.InputActive())
+	assert.FileExists(t, filepath.Join(dir, "date"))
+}
+
+func TestShrinkingDirectoryKeepsCursorInRange(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "a", "b", "c", "d"))
+	m = press(m, "G")
+	require.Equal(t, 3, m.selected)
+
+	m, _ = m.Update(readDirMsg{gen: m.readGen, entries: m.files[:2]})
+	assert.Equal(t, 1, m.selected)
+	assert.Equal(t, 0, m.min)
+
+	m, _ = m.Update(readDirMsg{gen: m.readGen})
+	assert.NotPanics(t, func() {
+		m = press(m, "down", "up", "G", "g", "enter")
+		m.View()
+	})
+	assert.Equal(t, 0, m.selected)
+}
+
+func TestToggleHiddenShowsAndHidesDotfiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dotfiles aren't hidden on Windows")
+	}
+	m := newTestModel(t, makeTree(t, ".hidden", "a", "b"))
+	require.Equal(t, []string{"a", "b"}, names(m))
+	m = press(m, "down")
+
+	m = press(m, ".")
+	assert.True(t, m.ShowHidden)
+	assert.Equal(t, []string{".hidden", "a", "b"}, names(m))
+	assert.Equal(t, 0, m.selected)
+
+	m = press(m, ".")
+	assert.False(t, m.ShowHidden)
+	assert.Equal(t, []string{"a", "b"}, names(m))
+}
+
+func TestMatchesType(t *testing.T) {
+	for _, tt := range []struct {
+		file, pattern string
+		match         bool
+	}{
+		{"main.go", "*.go", true},
+		{"main_test.go", "*.go", true},
+		{"main.go.orig", "*.go", false},
+		{"notes.txt", ".txt", true},
+		{"notes.txt.bak", ".txt", false},
+		{"photo.png", ".png", true},
+		{"data-01.json", "data-??.json", true},
+		{"data-1.json", "data-??.json", false},
+		{"data-001.json", "data-??.json", false},
+		{"backup.tar.gz", "*.tar.gz", true},
+		{"backup.gz", "*.tar.gz", false},
+		// Globs are matched against the base name.
+		{filepath.Join("sub", "data-02.json"), "data-??.json", true},
+	} {
+		assert.Equal(t, tt.match, matchesType(tt.file, tt.pattern), "%s against %s", tt.file, tt.pattern)
+	}
+}
+
+func TestPagingShortDirectory(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "a", "b", "c"))
+	require.Equal(t, 10, m.Height)
+
+	for _, keys := range [][]string{{"G"}, {"pgdown"}, {"pgdown", "pgdown"}, {"G", "pgup"}, {"pgup"}} {
+		m = press(m, keys...)
+		assert.Equal(t, 0, m.min, "%v", keys)
+		assert.GreaterOrEqual(t, m.selected, 0, "%v", keys)
+		assert.Less(t, m.selected, 3, "%v", keys)
+	}
+
+	m = press(m, "G")
+	assert.Equal(t, 2, m.selected)
+	m = press(m, "g", "pgdown")
+	assert.Equal(t, 2, m.selected)
+	m = press(m, "pgup")
+	assert.Equal(t, 0, m.selected)
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	dir := makeTree(t, "target/inside")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(filepath.Join(dir, "target"), link); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+
+	m := newTestModel(t, dir)
+	require.Equal(t, []string{"target", "link"}, names(m))
+	m = press(m, "down", "l")
+	assert.Equal(t, link, m.CurrentDirectory)
+	assert.Equal(t, []string{"inside"}, names(m))
+
+	m = newTestModel(t, dir)
+	m.FollowSymlinks = false
+	m = press(m, "down", "l")
+	assert.Equal(t, dir, m.CurrentDirectory)
+
+	// The symlink is selected as a file, by its own path.
+	m, _ = m.Update(keyMsg(" "))
+	assert.Equal(t, link, m.Path)
+	didSelect, path := m.DidSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, link, path)
+}
+
+func TestMaxFileSizeDisablesLargerFiles(t *testing.T) {
+	// Each file holds its own name, so its name tells its size.
+	dir := makeTree(t, "a-large-file", "small")
+	m := newTestModel(t, dir)
+	m.MaxFileSize = 5
+	require.Equal(t, []string{"a-large-file", "small"}, names(m))
+
+	assert.False(t, m.canSelect(m.files[0]))
+	assert.True(t, m.entryRow(m.files[0]).disabled)
+	assert.True(t, m.canSelect(m.files[1]))
+	assert.False(t, m.entryRow(m.files[1]).disabled)
+
+	m, _ = m.Update(keyMsg(" "))
+	assert.Empty(t, m.Path)
+	didSelect, path := m.DidSelectDisabledFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "a-large-file"), path)
+
+	m = press(m, "down")
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, path = m.DidSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "small"), path)
+}
+
+func TestAllowedTypesIgnoreCase(t *testing.T) {
+	var m Model
+	for _, tt := range []struct {
+		name      string
+		types     []string
+		match     bool
+		sensitive bool
+	}{
+		{"photo.jpg", []string{".JPG"}, true, false},
+		{"photo.JPG", []string{".jpg"}, true, false},
+		{"Photo.JpG", []string{".jPg", ".png"}, true, false},
+		{"photo.jpg", []string{".png"}, false, false},
+		{"Report-01.CSV", []string{"report-??.csv"}, true, false},
+		{"photo.jpg", []string{".JPG"}, false, true},
+		{"photo.JPG", []string{".JPG"}, true, true},
+		{"Report-01.CSV", []string{"report-??.csv"}, false, true},
+	} {
+		m.AllowedTypes, m.CaseSensitiveTypes = tt.types, tt.sensitive
+		assert.Equal(t, tt.match, m.selectable(nil, tt.name), "%s against %v, case sensitive: %v", tt.name, tt.types, tt.sensitive)
+	}
+}
+
+func TestDidSelectFileNeedsPath(t *testing.T) {
+	dir := makeTree(t, "sub/", "a.txt")
+	m := newTestModel(t, dir)
+	m.DirAllowed = true
+	m = press(m, "down")
+	require.Equal(t, "a.txt", m.files[m.selected].Name())
+
+	// Before the key is handled there is no path, so nothing is selected yet.
+	didSelect, path := m.didSelectFile(keyMsg(" "))
+	assert.False(t, didSelect)
+	assert.Empty(t, path)
+
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, path = m.didSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "a.txt"), path)
+
+	m = press(m, "up")
+	m.Path = ""
+	didSelect, _ = m.didSelectFile(keyMsg(" "))
+	assert.False(t, didSelect)
+}
+
+func TestStartOnPutsCursorOnEntry(t *testing.T) {
+	dir := makeTree(t, numbered(30)...)
+	m := newTestModel(t, dir, func(m *Model) { m.StartOn = "f17" })
+	assert.Equal(t, 17, m.selected)
+	assert.LessOrEqual(t, m.min, 17)
+	assert.GreaterOrEqual(t, m.max, 17)
+	assert.Contains(t, m.View(), "f17")
+
+	// StartOn only applies to the initial directory.
+	m = run(m, m.readDirCmd())
+	assert.Equal(t, 17, m.selected)
+	m = press(m, "g")
+	m = run(m, m.readDirCmd())
+	assert.Equal(t, 0, m.selected)
+
+	m = newTestModel(t, dir, func(m *Model) { m.StartOn = "missing" })
+	assert.Equal(t, 0, m.selected)
+}
+
+func TestWrapNavigation(t *testing.T) {
+	m := newTestModel(t, makeTree(t, numbered(30)...))
+	m = press(m, "up")
+	assert.Equal(t, 0, m.selected, "no wrapping by default")
+
+	m.WrapNavigation = true
+	m = press(m, "up")
+	assert.Equal(t, 29, m.selected)
+	assert.Equal(t, 20, m.min)
+	assert.Equal(t, 29, m.max)
+
+	m = press(m, "down")
+	assert.Equal(t, 0, m.selected)
+	assert.Equal(t, 0, m.min)
+	assert.Equal(t, 9, m.max)
+
+	m.WrapNavigation = false
+	m = press(m, "G", "down")
+	assert.Equal(t, 29, m.selected, "no wrapping by default")
+}
+
+func TestHiddenExtensionsAreNeverListed(t *testing.T) {
+	dir := makeTree(t, "a.txt", "a.txt.swp", "b.TMP", ".c.tmp", "keep.tmpl")
+	for _, showHidden := range []bool{false, true} {
+		m := newTestModel(t, dir, WithShowHidden(showHidden), func(m *Model) {
+			m.HiddenExtensions = []string{".tmp", "*.swp"}
+		})
+		assert.Equal(t, []string{"a.txt", "keep.tmpl"}, names(m), "ShowHidden: %v", showHidden)
+	}
+}
+
+func TestSelectedInfo(t *testing.T) {
+	m := newTestModel(t, makeTree(t))
+	_, err := m.SelectedInfo()
+	assert.ErrorIs(t, err, ErrEmptyDirectory)
+
+	m = newTestModel(t, makeTree(t, "a", "bb"))
+	m = press(m, "down")
+	info, err := m.SelectedInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "bb", info.Name())
+	assert.EqualValues(t, 2, info.Size())
+	assert.True(t, info.Mode().IsRegular())
+}
+
+func TestReloadShowsNewEntries(t *testing.T) {
+	dir := makeTree(t, "b", "d")
+	m := newTestModel(t, dir)
+	m = press(m, "down")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), nil, 0o644))
+
+	m = press(m, "ctrl+r")
+	assert.Equal(t, []string{"a", "b", "d"}, names(m))
+	// The cursor stays on the entry it was on.
+	assert.Equal(t, "d", m.files[m.selected].Name())
+}
+
+func TestSymlinkLoopIsNotFollowed(t *testing.T) {
+	dir := makeTree(t, "sub/")
+	sub := filepath.Join(dir, "sub")
+	if err := os.Symlink(dir, filepath.Join(sub, "loop")); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	require.NoError(t, os.Symlink(".", filepath.Join(sub, "self")))
+
+	m := newTestModel(t, dir)
+	m = press(m, "l")
+	require.Equal(t, sub, m.CurrentDirectory)
+	require.Equal(t, []string{"loop", "self"}, names(m))
+
+	for i, name := range []string{"loop", "self"} {
+		m.SetCursor(i)
+		m = press(m, "l")
+		assert.Equal(t, sub, m.CurrentDirectory, name)
+		assert.Error(t, m.err, name)
+	}
+}
+
+func TestUnreadableDirectoryGoesBack(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("needs permission bits that apply to the user")
+	}
+	dir := makeTree(t, "locked/", "open/")
+	locked := filepath.Join(dir, "locked")
+	require.NoError(t, os.Chmod(locked, 0o000))
+	t.Cleanup(func() { os.Chmod(locked, 0o755) })
+
+	m := newTestModel(t, dir)
+	require.Equal(t, []string{"locked", "open"}, names(m))
+	m = press(m, "l")
+	assert.Equal(t, dir, m.CurrentDirectory)
+	assert.ErrorIs(t, m.err, os.ErrPermission)
+	assert.Contains(t, m.View(), "permission denied")
+	assert.Equal(t, []string{"locked", "open"}, names(m))
+
+	// The picker still works after the error.
+	m = press(m, "down", "l")
+	assert.Equal(t, filepath.Join(dir, "open"), m.CurrentDirectory)
+	assert.NoError(t, m.err)
+}
+
+func TestCursorStrings(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "a.txt", "b.bin"), WithAllowedTypes(".txt"))
+	m.Cursor, m.DisabledCursorString = ">", "✗"
+
+	// line returns the row of the listing that shows name.
+	line := func(name string) string {
+		for _, l := range strings.Split(strings.TrimPrefix(m.View(), m.headerView()), "\n") {
+			if strings.Contains(l, name) {
+				return l
+			}
+		}
+		return ""
+	}
+	assert.Contains(t, line("a.txt"), ">")
+	assert.NotContains(t, line("b.bin"), "✗")
+
+	m = press(m, "down")
+	assert.Contains(t, line("b.bin"), "✗")
+	assert.NotContains(t, line("b.bin"), ">")
+	assert.NotContains(t, line("a.txt"), ">")
+
+	// Without a cursor, only the style tells the selected row apart.
+	m.Cursor, m.DisabledCursorString = "", ""
+	assert.NotContains(t, line("b.bin"), "✗")
+	assert.True(t, strings.HasPrefix(line("a.txt"), "-"), line("a.txt"))
+}
+
+func TestConfineToRoot(t *testing.T) {
+	parent := makeTree(t, "root/a/b/", "root/z/", "outside/")
+	root := filepath.Join(parent, "root")
+	m := newTestModel(t, root, func(m *Model) { m.ConfineToRoot = true })
+
+	m = press(m, "h")
+	assert.Equal(t, root, m.CurrentDirectory, "Back at the root")
+
+	m = press(m, "l", "l")
+	require.Equal(t, filepath.Join(root, "a", "b"), m.CurrentDirectory)
+	m = press(m, "H")
+	assert.Equal(t, root, m.CurrentDirectory)
+	assert.Equal(t, "a", m.files[m.selected].Name())
+	m = press(m, "h")
+	assert.Equal(t, root, m.CurrentDirectory)
+
+	m = run(m, m.jumpTo(filepath.Join(parent, "outside")))
+	assert.Equal(t, root, m.CurrentDirectory)
+	assert.Error(t, m.err)
+
+	// Without confinement, Back leaves the initial directory.
+	m = newTestModel(t, root)
+	m = press(m, "h")
+	assert.Equal(t, parent, m.CurrentDirectory)
+}
+
+func TestRootDirectoryCantBeEscaped(t *testing.T) {
+	parent := makeTree(t, "root/sub/", "outside/secret")
+	root := filepath.Join(parent, "root")
+	if err := os.Symlink(filepath.Join(parent, "outside"), filepath.Join(root, "escape")); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	require.NoError(t, os.Symlink("sub", filepath.Join(root, "inside")))
+
+	m := newTestModel(t, filepath.Join(root, "sub"), func(m *Model) { m.RootDirectory = root })
+	m = press(m, "h")
+	require.Equal(t, root, m.CurrentDirectory)
+	m = press(m, "h")
+	assert.Equal(t, root, m.CurrentDirectory, "Back at the root")
+
+	require.Equal(t, []string{"sub", "escape", "inside"}, names(m))
+	m = press(m, "down", "l")
+	assert.Equal(t, root, m.CurrentDirectory, "symlink out of the root")
+	assert.Error(t, m.err)
+
+	// Symlinks that stay within the root are followed.
+	m = press(m, "down", "l")
+	assert.Equal(t, filepath.Join(root, "inside"), m.CurrentDirectory)
+}
+
+// listing returns the rows of the listing in the view, without the header.
+func listing(m Model) []string {
+	var rows []string
+	for _, row := range strings.Split(strings.TrimPrefix(m.View(), m.headerView()), "\n") {
+		if strings.TrimSpace(row) != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestSizeColumn(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "sub/", "abc"))
+	require.Equal(t, []string{"sub", "abc"}, names(m))
+
+	dir, file := m.entryRow(m.files[0]), m.entryRow(m.files[1])
+	assert.Equal(t, "-", strings.TrimSpace(dir.sizeText))
+	assert.Equal(t, m.sizeWidth(), lipgloss.Width(file.sizeText))
+	assert.True(t, strings.HasSuffix(file.sizeText, "3 B"), "%q", file.sizeText)
+	m.SizeAlign = lipgloss.Left
+	file = m.entryRow(m.files[1])
+	assert.True(t, strings.HasPrefix(file.sizeText, "3 B"), "%q", file.sizeText)
+	assert.Equal(t, m.sizeWidth(), lipgloss.Width(file.sizeText))
+
+	rows := listing(m)
+	require.Len(t, rows, 2)
+	assert.Contains(t, rows[0], " - ")
+	assert.Contains(t, rows[1], "3 B")
+	m.ShowSize = false
+	for _, row := range listing(m) {
+		assert.NotContains(t, row, " - ")
+		assert.NotContains(t, row, "3 B")
+	}
+}
+
+func TestUseBinaryUnits(t *testing.T) {
+	dir := makeTree(t, "big")
+	require.NoError(t, os.Truncate(filepath.Join(dir, "big"), 1048576))
+	m := newTestModel(t, dir)
+
+	assert.Contains(t, listing(m)[0], "1.0 MB")
+	m.UseBinaryUnits = true
+	row := m.entryRow(m.files[0])
+	assert.Equal(t, "1.0 MiB", strings.TrimSpace(row.sizeText))
+	assert.Contains(t, listing(m)[0], "1.0 MiB")
+	// The column fits the longest sizes in these units.
+	assert.GreaterOrEqual(t, m.sizeWidth(), lipgloss.Width("1023 KiB"))
+}
+
+func TestDirAllowedSelectsDirectories(t *testing.T) {
+	dir := makeTree(t, "folder/", "a.txt")
+	m := newTestModel(t, dir)
+	assert.NotContains(t, listing(m)[0], "◆")
+	m.DirAllowed = true
+	assert.Contains(t, listing(m)[0], "◆")
+	assert.NotContains(t, listing(m)[1], "◆")
+
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, path := m.DidSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "folder"), path)
+	didSelect, path = m.DidSelectDirectory(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "folder"), path)
+}
+
+func TestClassifyEntries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("files have no executable bits on Windows")
+	}
+	long := "a-very-long-name-that-does-not-fit-in-the-row.sh"
+	dir := makeTree(t, "sub/", "plain", "tool", long)
+	for _, name := range []string{"tool", long} {
+		require.NoError(t, os.Chmod(filepath.Join(dir, name), 0o755))
+	}
+	require.NoError(t, os.Symlink("plain", filepath.Join(dir, "link")))
+	m := newTestModel(t, dir, WithWidth(40))
+	require.Equal(t, []string{"sub", long, "link", "plain", "tool"}, names(m))
+
+	m.ClassifyEntries = true
+	// Keep the cursor, and the style of its row, off the rows checked below.
+	m.SetCursor(3)
+	rows := listing(m)
+	require.Len(t, rows, 5)
+	assert.True(t, strings.HasSuffix(rows[0], "sub/"), rows[0])
+	// The indicator stays after names that are cut off.
+	assert.True(t, strings.HasSuffix(rows[1], "…*"), rows[1])
+	assert.True(t, strings.HasSuffix(rows[2], "@"), rows[2])
+	assert.Contains(t, rows[3], "plain")
+	assert.NotContains(t, rows[3], "plain*")
+	assert.True(t, strings.HasSuffix(rows[4], "tool*"), rows[4])
+
+	m.ClassifyEntries = false
+	assert.True(t, strings.HasSuffix(listing(m)[0], "sub"))
+}
+
+func TestCompactView(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "sub/", "a.txt", "b.md"), WithWidth(40))
+	m.Compact = true
+	m = press(m, "down")
+
+	want := "   sub\n" +
+		m.Styles.Cursor.Render(">>") + m.Styles.Selected.Render(" a.txt") + "\n" +
+		"   b.md\n"
+	assert.Equal(t, want, strings.TrimPrefix(m.View(), m.headerView()))
+}
+
+func TestShowFullPaths(t *testing.T) {
+	dir := makeTree(t, "a.txt", "the-base-name-that-stays.txt")
+	m := newTestModel(t, dir)
+	m.ShowFullPaths = true
+	// Keep the cursor, and the style of its row, off the row that is checked.
+	m.SetCursor(1)
+	assert.Contains(t, listing(m)[0], filepath.Join(dir, "a.txt"))
+
+	// Cut-off paths lose their start rather than the base name.
+	m.Width = 60
+	m.SetCursor(0)
+	row := listing(m)[1]
+	assert.Contains(t, row, "…")
+	assert.True(t, strings.HasSuffix(row, string(filepath.Separator)+"the-base-name-that-stays.txt"), row)
+	assert.NotContains(t, row, dir)
+	assert.LessOrEqual(t, lipgloss.Width(row), 60)
+}
+
+func TestStaleReadIsDropped(t *testing.T) {
+	dir := makeTree(t, "old/a", "new/b")
+	m := newTestModel(t, filepath.Join(dir, "old"))
+
+	m.readGen++
+	first := m.readDirCmd()()
+	m.CurrentDirectory = filepath.Join(dir, "new")
+	m.readGen++
+	second := m.readDirCmd()()
+
+	m, _ = m.Update(second)
+	require.Equal(t, []string{"b"}, names(m))
+	// The first read finishes last, but its listing is out of date.
+	m, _ = m.Update(first)
+	assert.Equal(t, []string{"b"}, names(m))
+
+	// So are errors of outdated reads.
+	m, _ = m.Update(readDirErrorMsg{gen: m.readGen - 1, err: os.ErrPermission})
+	assert.NoError(t, m.err)
+}
+
+func TestMarginBottom(t *testing.T) {
+	m := New()
+	require.True(t, m.AutoHeight)
+	for _, margin := range []int{0, 5, 12} {
+		m.MarginBottom = margin
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+		assert.Equal(t, 40-margin, m.Height, "MarginBottom %d", margin)
+	}
+
+	// The height doesn't fall below MinHeight, however big the margin is.
+	m.MarginBottom, m.MinHeight = 38, 3
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	assert.Equal(t, 3, m.Height)
+}
+
+func TestPathBox(t *testing.T) {
+	m := New()
+	m.Width = 30
+	m.PathUI = "/home/me/notes.txt"
+	assert.Equal(t, ""+
+		"╭────────────────────────────╮\n"+
+		"│     /home/me/notes.txt     │\n"+
+		"╰────────────────────────────╯\n"+
+		"                              \n\n", m.headerView())
+
+	m.ShowPathBox = false
+	assert.Equal(t, "  /home/me/notes.txt\n\n", m.headerView())
+}
+
+func TestSelectModeCycles(t *testing.T) {
+	m := newTestModel(t, makeTree(t, "sub/", "a.txt"))
+	m.ShowHelp = true
+	dir, file := m.files[0], m.files[1]
+	for _, tt := range []struct {
+		mode         string
+		dirs, files  bool
+		fileDisabled bool
+	}{
+		{"files", false, true, false},
+		{"dirs", true, false, true},
+		{"both", true, true, false},
+		{"files", false, true, false},
+	} {
+		assert.Equal(t, tt.dirs, m.canSelect(dir), tt.mode)
+		assert.Equal(t, tt.files, m.canSelect(file), tt.mode)
+		assert.Equal(t, tt.fileDisabled, m.entryRow(file).disabled, tt.mode)
+		assert.Contains(t, m.helpView(), "select: "+tt.mode)
+		m = press(m, "t")
+	}
+}
+
+func TestSortFunc(t *testing.T) {
+	dir := makeTree(t, "b", "sub/", "c", "a")
+	reverse := func(a, b os.DirEntry) bool { return a.Name() > b.Name() }
+
+	m := newTestModel(t, dir, func(m *Model) { m.SortFunc = reverse })
+	assert.Equal(t, []string{"sub", "c", "b", "a"}, names(m))
+
+	// Directories are only kept on top with DirsFirst.
+	byName := func(a, b os.DirEntry) bool { return a.Name() < b.Name() }
+	m = newTestModel(t, dir, func(m *Model) { m.SortFunc = byName })
+	assert.Equal(t, []string{"a", "b", "c", "sub"}, names(m))
+	m = newTestModel(t, dir, func(m *Model) { m.SortFunc = DirsFirst(byName) })
+	assert.Equal(t, []string{"sub", "a", "b", "c"}, names(m))
+}
+
+func TestExtensionStyles(t *testing.T) {
+	png := lipgloss.NewStyle().Underline(true)
+	gz := lipgloss.NewStyle().Italic(true)
+	m := newTestModel(t, makeTree(t, "a.txt", "backup.tar.gz", "photo.PNG"))
+	m.ExtensionStyles = map[string]lipgloss.Style{".png": png, ".gz": gz}
+
+	assert.Equal(t, png, m.fileStyle("photo.PNG"))
+	assert.Equal(t, gz, m.fileStyle("backup.tar.gz"))
+	assert.Equal(t, m.Styles.File, m.fileStyle("a.txt"))
+	assert.Equal(t, m.Styles.File, m.fileStyle("tar"))
+
+	rows := listing(m)
+	require.Len(t, rows, 3)
+	assert.Contains(t, rows[2], png.Render("photo.PNG"))
+	assert.Contains(t, rows[1], gz.Render("backup.tar.gz"))
+}
+
+func TestDisabledColorsRenderNoEscapes(t *testing.T) {
+	// Without a terminal, lipgloss renders no colors at all.
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+	t.Setenv("LS_COLORS", "di=01;34:*.txt=01;32")
+
+	dir := makeTree(t, "sub/", "a.txt", "b.bin", "c.txt")
+	opts := []Option{WithAllowedTypes(".txt"), WithLSColors(), func(m *Model) { m.MultiSelect = true }}
+	m := press(newTestModel(t, dir, opts...), "down", " ")
+	require.Contains(t, m.View(), "\x1b[")
+
+	m.DisableColors()
+	assert.NotContains(t, m.View(), "\x1b[")
+
+	t.Setenv("NO_COLOR", "1")
+	m = press(newTestModel(t, dir, opts...), "down", " ")
+	assert.NotContains(t, m.View(), "\x1b[")
+}
+
+func TestPlainStylesKeepLayout(t *testing.T) {
+	styles := Styles{
+		Badge: lipgloss.NewStyle().Width(12).Padding(0, 1).Bold(true).
+			Foreground(lipgloss.Color("78")).
+			Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("63")),
+	}
+	plain := plainStyles(styles).Badge
+	assert.Equal(t, 12, plain.GetWidth())
+	assert.Equal(t, 1, plain.GetPaddingLeft())
+	assert.Equal(t, lipgloss.RoundedBorder(), plain.GetBorderStyle())
+	assert.True(t, plain.GetBorderTop())
+	assert.False(t, plain.GetBold())
+	assert.Equal(t, lipgloss.NoColor{}, plain.GetForeground())
+	assert.Equal(t, lipgloss.NoColor{}, plain.GetBorderTopForeground())
+	// The styles it was given are left alone.
+	assert.True(t, styles.Badge.GetBold())
+}
+
+func TestMoveAndSetCursorScroll(t *testing.T) {
+	m := newTestModel(t, makeTree(t, numbered(30)...))
+
+	m.SetCursor(15)
+	assert.Equal(t, 15, m.selected)
+	assert.Equal(t, []int{6, 15}, []int{m.min, m.max})
+	// Moving within the view doesn't scroll it.
+	m.MoveCursor(-5)
+	assert.Equal(t, 10, m.selected)
+	assert.Equal(t, []int{6, 15}, []int{m.min, m.max})
+	// Moving out of it scrolls by as much as the cursor moved, but not above
+	// the first entry.
+	m.MoveCursor(-4)
+	assert.Equal(t, 6, m.selected)
+	m.MoveCursor(-3)
+	assert.Equal(t, 3, m.selected)
+	assert.Equal(t, []int{3, 12}, []int{m.min, m.max})
+	m.MoveCursor(12)
+	assert.Equal(t, 15, m.selected)
+	assert.Equal(t, []int{15, 24}, []int{m.min, m.max})
+	m.MoveCursor(-13)
+	assert.Equal(t, 2, m.selected)
+	assert.Equal(t, []int{2, 11}, []int{m.min, m.max})
+	m.MoveCursor(-1)
+	m.MoveCursor(-1)
+	assert.Equal(t, 0, m.selected)
+	assert.Equal(t, []int{0, 9}, []int{m.min, m.max})
+
+	// Both clamp to the listed entries.
+	m.MoveCursor(100)
+	assert.Equal(t, 29, m.selected)
+	assert.Equal(t, []int{20, 29}, []int{m.min, m.max})
+	m.SetCursor(-3)
+	assert.Equal(t, 0, m.selected)
+	assert.Equal(t, []int{0, 9}, []int{m.min, m.max})
+	m.SetCursor(99)
+	assert.Equal(t, 29, m.selected)
+	assert.Equal(t, []int{20, 29}, []int{m.min, m.max})
+}
+
+func TestPageDownUpdatesPathUI(t *testing.T) {
+	dir := makeTree(t, numbered(30)...)
+	m := newTestModel(t, dir)
+	m = press(m, "pgdown")
+	require.Equal(t, 10, m.selected)
+	assert.Equal(t, filepath.Join(dir, "f10"), m.PathUI)
+	m = press(m, "J")
+	assert.Equal(t, filepath.Join(dir, "f20"), m.PathUI)
+}
+
+func TestJumpKeysUpdatePathUI(t *testing.T) {
+	dir := makeTree(t, append([]string{"sub/"}, numbered(25)...)...)
+	m := newTestModel(t, dir)
+	for _, tt := range []struct {
+		key, want string
+	}{
+		{"G", filepath.Join(dir, "f24")},
+		{"pgup", filepath.Join(dir, "f14")},
+		{"g", dir},
+		{"pgdown", filepath.Join(dir, "f09")},
+		{"g", dir},
+		{"G", filepath.Join(dir, "f24")},
+		{"K", filepath.Join(dir, "f14")},
+	} {
+		m = press(m, tt.key)
+		assert.Equal(t, tt.want, m.PathUI, tt.key)
+	}
+}
+
+func TestSetCurrentDirectory(t *testing.T) {
+	dir := makeTree(t, "sub/a", "b")
+	m := New()
+	require.NoError(t, m.SetCurrentDirectory(filepath.Join(dir, "sub")))
+	assert.Equal(t, filepath.Join(dir, "sub"), m.PathUI)
+	m = run(m, m.Init())
+	assert.Equal(t, filepath.Join(dir, "sub"), m.CurrentDirectory)
+	assert.Equal(t, []string{"a"}, names(m))
+
+	assert.Error(t, m.SetCurrentDirectory(filepath.Join(dir, "missing")))
+	assert.Error(t, m.SetCurrentDirectory(filepath.Join(dir, "b")))
+	assert.Equal(t, filepath.Join(dir, "sub"), m.CurrentDirectory)
+}
+
+func TestEmptyMessage(t *testing.T) {
+	m := newTestModel(t, makeTree(t))
+	assert.Contains(t, m.View(), DefaultMessages.EmptyDirectory)
+
+	m.EmptyMessage = "Nothing to copy here."
+	assert.Contains(t, m.View(), "Nothing to copy here.")
+	assert.NotContains(t, m.View(), DefaultMessages.EmptyDirectory)
+}
+
+func TestDidSelectDirectory(t *testing.T) {
+	dir := makeTree(t, "folder/", "a.txt")
+	m := newTestModel(t, dir, WithDirAllowed(true))
+
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, path := m.DidSelectDirectory(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "folder"), path)
+
+	m = newTestModel(t, dir, WithDirAllowed(true))
+	m = press(m, "down")
+	m, _ = m.Update(keyMsg(" "))
+	didSelect, _ = m.DidSelectDirectory(keyMsg(" "))
+	assert.False(t, didSelect, "a file")
+	didSelect, path = m.DidSelectFile(keyMsg(" "))
+	assert.True(t, didSelect)
+	assert.Equal(t, filepath.Join(dir, "a.txt"), path)
+}
+
+func TestDirCountsAreCached(t *testing.T) {
+	var tree []string
+	for i := 0; i < 8; i++ {
+		tree = append(tree, fmt.Sprintf("d%d/a", i))
+	}
+	tree = append(tree, "d0/b", "d0/.h")
+	dir := makeTree(t, tree...)
+	m := NewWithOptions(WithPath(dir), WithHeight(3), func(m *Model) { m.ShowDirCounts = true })
+	m = run(m, m.readDirCmd())
+
+	// Only the directories in the window are counted.
+	assert.Equal(t, map[string]int{
+		filepath.Join(dir, "d0"): 2,
+		filepath.Join(dir, "d1"): 1,
+		filepath.Join(dir, "d2"): 1,
+	}, m.dirCounts)
+	assert.Contains(t, m.View(), "d1 (1)")
+
+	// A directory coming into view shows a placeholder until it is counted,
+	// and isn't counted twice meanwhile.
+	m, cmd := m.Update(keyMsg("G"))
+	require.NotNil(t, cmd)
+	assert.Equal(t, dirCountPending, m.dirCounts[filepath.Join(dir, "d7")])
+	assert.Contains(t, m.View(), "d6 (...)")
+	m, again := m.Update(keyMsg("k"))
+	assert.Nil(t, again)
+	m = run(m, cmd)
+	assert.Contains(t, m.View(), "d7 (1)")
+
+	// Counts are kept until the listing is reloaded.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d0", "c"), nil, 0o644))
+	m = press(m, "g")
+	assert.Equal(t, 2, m.dirCounts[filepath.Join(dir, "d0")])
+	m = press(m, "ctrl+r")
+	assert.Equal(t, 3, m.dirCounts[filepath.Join(dir, "d0")])
+	assert.NotContains(t, m.dirCounts, filepath.Join(dir, "d7"))
+
+	if runtime.GOOS != "windows" {
+		// Hidden entries count once they are shown.
+		m = press(m, ".")
+		assert.Equal(t, 4, m.dirCounts[filepath.Join(dir, "d0")])
+	}
+}
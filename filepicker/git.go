@@ -0,0 +1,91 @@
+package filepicker
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// gitStatus is the state of an entry in the git repository it is in. A
+// directory has the highest state of the files below it.
+type gitStatus int
+
+const (
+	gitUnchanged gitStatus = iota
+	gitStaged
+	gitUntracked
+	gitModified
+)
+
+type gitStatusMsg struct {
+	dir      string
+	statuses map[string]gitStatus
+}
+
+// gitStatusCmd returns a command that asks git for the status of the entries
+// of the current directory, or nil if ShowGitStatus is off or the directory
+// isn't on the local disk. Outside of a repository, git fails and the command
+// reports nothing.
+func (m Model) gitStatusCmd() tea.Cmd {
+	if !m.ShowGitStatus || m.FileSystem != nil {
+		return nil
+	}
+	dir := m.CurrentDirectory
+	return func() tea.Msg {
+		// The paths in the output are relative to the root of the repository,
+		// so the path of the directory within it is cut off of them.
+		prefix, err := exec.Command("git", "-C", dir, "rev-parse", "--show-prefix").Output()
+		if err != nil {
+			return nil
+		}
+		out, err := exec.Command("git", "-C", dir, "status", "--porcelain", "-z", "--", ".").Output()
+		if err != nil {
+			return nil
+		}
+		return gitStatusMsg{dir: dir, statuses: parsePorcelain(out, strings.TrimSpace(string(prefix)))}
+	}
+}
+
+// parsePorcelain parses the output of git status --porcelain -z into the
+// status of each entry of the directory at prefix, relative to the root of
+// the repository. Files below a subdirectory count for the subdirectory, and
+// ignored files are left out.
+func parsePorcelain(out []byte, prefix string) map[string]gitStatus {
+	statuses := map[string]gitStatus{}
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		field := string(fields[i])
+		if len(field) < 4 {
+			continue
+		}
+		x, y, path := field[0], field[1], field[3:]
+		if x == 'R' || x == 'C' {
+			// The original path of a rename or copy follows in its own field.
+			i++
+		}
+
+		var status gitStatus
+		switch {
+		case x == '!':
+			continue
+		case x == '?':
+			status = gitUntracked
+		case y != ' ':
+			status = gitModified
+		default:
+			status = gitStaged
+		}
+
+		rel := strings.TrimPrefix(path, prefix)
+		if rel == path && prefix != "" {
+			continue
+		}
+		name := strings.SplitN(strings.TrimSuffix(rel, "/"), "/", 2)[0]
+		if status > statuses[name] {
+			statuses[name] = status
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,55 @@
+package filepicker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePorcelain(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    []string
+		prefix string
+		want   map[string]gitStatus
+	}{
+		{
+			name: "states",
+			out:  []string{" M a.go", "M  b.go", "MM c.go", "?? d.go", "!! e.go", "A  f.go"},
+			want: map[string]gitStatus{"a.go": gitModified, "b.go": gitStaged, "c.go": gitModified, "d.go": gitUntracked, "f.go": gitStaged},
+		},
+		{
+			// The original path of a rename is skipped, not read as an entry.
+			name: "rename",
+			out:  []string{"R  new.go", "old.go", "?? g.go"},
+			want: map[string]gitStatus{"new.go": gitStaged, "g.go": gitUntracked},
+		},
+		{
+			// A directory takes the highest state of the files below it.
+			name: "subdirectories",
+			out:  []string{"M  sub/a.go", " M sub/deep/b.go", "?? other/", "A  top.go"},
+			want: map[string]gitStatus{"sub": gitModified, "other": gitUntracked, "top.go": gitStaged},
+		},
+		{
+			// Paths are relative to the root; those outside prefix are left out.
+			name:   "prefix",
+			out:    []string{" M pkg/a.go", "?? pkg/sub/b.go", " M cmd/main.go"},
+			prefix: "pkg/",
+			want:   map[string]gitStatus{"a.go": gitModified, "sub": gitUntracked},
+		},
+		{
+			name: "clean",
+			want: map[string]gitStatus{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out string
+			if len(tt.out) > 0 {
+				out = strings.Join(tt.out, "\x00") + "\x00"
+			}
+			assert.Equal(t, tt.want, parsePorcelain([]byte(out), tt.prefix))
+		})
+	}
+}
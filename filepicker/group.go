@@ -0,0 +1,153 @@
+package filepicker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileGroup is one of the sections of the listing when GroupByType is set.
+type fileGroup int
+
+const (
+	groupDirectories fileGroup = iota
+	groupImages
+	groupDocuments
+	groupOther
+)
+
+// name returns the header of the group in messages.
+func (g fileGroup) name(messages Messages) string {
+	switch g {
+	case groupDirectories:
+		return messages.Directories
+	case groupImages:
+		return messages.Images
+	case groupDocuments:
+		return messages.Documents
+	default:
+		return messages.Other
+	}
+}
+
+// groupExtensions maps lowercase extensions to the group of the files that
+// have them. Anything else is in groupOther.
+var groupExtensions = map[string]fileGroup{
+	".bmp":  groupImages,
+	".gif":  groupImages,
+	".ico":  groupImages,
+	".jpeg": groupImages,
+	".jpg":  groupImages,
+	".png":  groupImages,
+	".svg":  groupImages,
+	".tif":  groupImages,
+	".tiff": groupImages,
+	".webp": groupImages,
+	".csv":  groupDocuments,
+	".doc":  groupDocuments,
+	".docx": groupDocuments,
+	".md":   groupDocuments,
+	".odp":  groupDocuments,
+	".ods":  groupDocuments,
+	".odt":  groupDocuments,
+	".pdf":  groupDocuments,
+	".ppt":  groupDocuments,
+	".pptx": groupDocuments,
+	".rtf":  groupDocuments,
+	".txt":  groupDocuments,
+	".xls":  groupDocuments,
+	".xlsx": groupDocuments,
+}
+
+// groupOf returns the group an entry is listed in.
+func groupOf(entry os.DirEntry) fileGroup {
+	if entry.IsDir() {
+		return groupDirectories
+	}
+	if group, ok := groupExtensions[strings.ToLower(filepath.Ext(entry.Name()))]; ok {
+		return group
+	}
+	return groupOther
+}
+
+// groupEntries moves the entries into the order of their groups, keeping the
+// order within each group.
+func groupEntries(entries []os.DirEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return groupOf(entries[i]) < groupOf(entries[j])
+	})
+}
+
+// groupHeaderBefore reports whether View renders a group header above the
+// entry at index i: above the first entry of each group, and above the first
+// visible entry so the group of the top row is always named.
+func (m Model) groupHeaderBefore(i int) bool {
+	if !m.GroupByType {
+		return false
+	}
+	return m.headerAbove(i, m.min)
+}
+
+// headerAbove reports whether a group header is rendered above the entry at
+// index i when the view starts at the entry at top.
+func (m Model) headerAbove(i, top int) bool {
+	return i == top || groupOf(m.files[i]) != groupOf(m.files[i-1])
+}
+
+// lastShown returns the index of the last entry that fits in Height rows when
+// the view starts at the entry at top. The group headers take up rows too,
+// though the entry at top is always shown.
+func (m Model) lastShown(top int) int {
+	if !m.GroupByType {
+		return top + m.Height - 1
+	}
+	rows := 0
+	for i := top; i < len(m.files); i++ {
+		rows++
+		if m.headerAbove(i, top) {
+			rows++
+		}
+		if rows > m.Height {
+			if i == top {
+				return top
+			}
+			return i - 1
+		}
+	}
+	return top + m.Height - 1
+}
+
+// firstShown returns the index of the topmost entry the view can start at and
+// still show the entry at last.
+func (m Model) firstShown(last int) int {
+	if !m.GroupByType {
+		if last-m.Height+1 < 0 {
+			return 0
+		}
+		return last - m.Height + 1
+	}
+	top := last
+	for top > 0 && m.lastShown(top-1) >= last {
+		top--
+	}
+	return top
+}
+
+// entryAtRow returns the index of the entry rendered in the given row of the
+// listing, counting group headers, or -1 if the row is a header or empty.
+func (m Model) entryAtRow(row int) int {
+	for i := m.min; i <= m.max && i < len(m.files); i++ {
+		if m.groupHeaderBefore(i) {
+			if row == 0 {
+				return -1
+			}
+			row--
+		}
+		if row == 0 {
+			return i
+		}
+		row--
+	}
+	return -1
+}
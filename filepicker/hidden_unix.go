@@ -3,9 +3,13 @@
 
 package filepicker
 
-import "strings"
+import (
+	"path/filepath"
+	"strings"
+)
 
-// IsHidden reports whether a file is hidden or not.
-func IsHidden(file string) (bool, error) {
-	return strings.HasPrefix(file, "."), nil
+// IsHidden reports whether the file at path is hidden, i.e. whether its base
+// name starts with a dot.
+func IsHidden(path string) (bool, error) {
+	return strings.HasPrefix(filepath.Base(path), "."), nil
 }
@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package filepicker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHidden(t *testing.T) {
+	dir := makeTree(t, ".profile", "notes.txt", ".config/", "src/")
+	for _, tt := range []struct {
+		name   string
+		hidden bool
+	}{
+		{".profile", true},
+		{".config", true},
+		{"notes.txt", false},
+		{"src", false},
+		{"missing", false},
+	} {
+		hidden, err := IsHidden(filepath.Join(dir, tt.name))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.hidden, hidden, tt.name)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"syscall"
 )
 
-// IsHidden reports whether a file is hidden or not.
-func IsHidden(file string) (bool, error) {
-	pointer, err := syscall.UTF16PtrFromString(file)
+// IsHidden reports whether the file at path is hidden, i.e. whether it has the
+// hidden file attribute set. The path must be resolvable from the working
+// directory, so pass the full path rather than just the base name.
+func IsHidden(path string) (bool, error) {
+	pointer, err := syscall.UTF16PtrFromString(path)
 	if err != nil {
 		return false, err
 	}
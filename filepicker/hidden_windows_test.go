@@ -0,0 +1,38 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHidden(t *testing.T) {
+	dir := makeTree(t, ".profile", "notes.txt", "secret.txt", "src/")
+	secret, err := syscall.UTF16PtrFromString(filepath.Join(dir, "secret.txt"))
+	require.NoError(t, err)
+	require.NoError(t, syscall.SetFileAttributes(secret, syscall.FILE_ATTRIBUTE_HIDDEN))
+
+	for _, tt := range []struct {
+		name   string
+		hidden bool
+	}{
+		{"secret.txt", true},
+		// Only the attribute counts, not the name.
+		{".profile", false},
+		{"notes.txt", false},
+		{"src", false},
+	} {
+		hidden, err := IsHidden(filepath.Join(dir, tt.name))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.hidden, hidden, tt.name)
+	}
+
+	_, err = IsHidden(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
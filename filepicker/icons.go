@@ -0,0 +1,81 @@
+package filepicker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Nerd Font glyphs shown when ShowIcons is set for entries that have no icon
+// of their own.
+const (
+	DefaultFileIcon = "" // nf-fa-file
+	DirectoryIcon   = "" // nf-fa-folder
+	SymlinkIcon     = "" // nf-fa-link
+)
+
+// DefaultIcons maps lower case file extensions to the Nerd Font glyphs shown
+// when ShowIcons is set. It is used unless Model.Icons is set.
+var DefaultIcons = map[string]string{
+	".go":   "", // nf-seti-go
+	".py":   "", // nf-seti-python
+	".js":   "", // nf-dev-javascript
+	".ts":   "", // nf-seti-typescript
+	".rs":   "", // nf-dev-rust
+	".c":    "", // nf-custom-c
+	".h":    "", // nf-custom-c
+	".cpp":  "", // nf-custom-cpp
+	".java": "", // nf-dev-java
+	".rb":   "", // nf-dev-ruby
+	".sh":   "", // nf-oct-terminal
+	".html": "", // nf-dev-html5
+	".css":  "", // nf-dev-css3
+	".json": "", // nf-seti-json
+	".yml":  "", // nf-seti-config
+	".yaml": "", // nf-seti-config
+	".toml": "", // nf-seti-config
+	".md":   "", // nf-seti-markdown
+	".txt":  "", // nf-fa-file_text
+	".pdf":  "", // nf-fa-file_pdf_o
+	".png":  "", // nf-fa-file_image_o
+	".jpg":  "", // nf-fa-file_image_o
+	".jpeg": "", // nf-fa-file_image_o
+	".gif":  "", // nf-fa-file_image_o
+	".svg":  "", // nf-fa-file_image_o
+	".zip":  "", // nf-oct-file_zip
+	".tar":  "", // nf-oct-file_zip
+	".gz":   "", // nf-oct-file_zip
+}
+
+// icon returns the glyph for an entry called name with the given mode.
+func (m Model) icon(name string, mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return SymlinkIcon
+	case mode.IsDir():
+		return DirectoryIcon
+	}
+	icons := m.Icons
+	if icons == nil {
+		icons = DefaultIcons
+	}
+	if icon, ok := icons[strings.ToLower(filepath.Ext(name))]; ok {
+		return icon
+	}
+	return DefaultFileIcon
+}
+
+// fileStyle returns the style of a regular file called name: its style in
+// ExtensionStyles, or Styles.File if it has none. Only the last extension of
+// names with several dots counts.
+func (m Model) fileStyle(name string) lipgloss.Style {
+	if m.colorsDisabled {
+		return m.Styles.File
+	}
+	if style, ok := m.ExtensionStyles[strings.ToLower(filepath.Ext(name))]; ok {
+		return style
+	}
+	return m.Styles.File
+}
@@ -0,0 +1,64 @@
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inspectTimeFormat is the layout of the modification time in the details of
+// an entry.
+const inspectTimeFormat = "2006-01-02 15:04:05 MST"
+
+// inspect opens the details of the entry under the cursor.
+func (m *Model) inspect() {
+	if len(m.files) == 0 {
+		return
+	}
+	m.inspecting = true
+}
+
+// inspectView returns the box with the details of the entry under the cursor,
+// centered where the list would be.
+func (m Model) inspectView() string {
+	f := m.files[m.selected]
+	info, err := f.Info()
+	if err != nil {
+		return m.Styles.Error.Render(err.Error()) + "\n"
+	}
+
+	type detail struct{ label, value string }
+	details := []detail{
+		{m.Messages.InspectPath, m.join(f.Name())},
+		{m.Messages.InspectSize, fmt.Sprintf("%d B (%s)", info.Size(), m.formatSize(info.Size()))},
+		{m.Messages.InspectMode, info.Mode().String()},
+		{m.Messages.InspectModified, info.ModTime().Format(inspectTimeFormat)},
+	}
+	if owner, group := fileOwner(info); owner != "" {
+		details = append(details, detail{m.Messages.InspectOwner, owner + ":" + group})
+	}
+	if info.Mode()&os.ModeSymlink != 0 && m.FileSystem == nil {
+		if target, err := os.Readlink(m.join(f.Name())); err == nil {
+			details = append(details, detail{m.Messages.InspectTarget, target})
+		}
+	}
+
+	var labelWidth int
+	for _, d := range details {
+		if w := lipgloss.Width(d.label); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	lines := make([]string, len(details))
+	for i, d := range details {
+		label := d.label + strings.Repeat(" ", labelWidth-lipgloss.Width(d.label))
+		lines[i] = m.Styles.InspectLabel.Render(label) + "  " + d.value
+	}
+	box := m.Styles.Inspect.Render(strings.Join(lines, "\n"))
+	if m.Width > 0 {
+		box = lipgloss.PlaceHorizontal(m.Width, lipgloss.Center, box)
+	}
+	return box + "\n"
+}
@@ -0,0 +1,169 @@
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ParseLSColors parses the colors of ls, given in the GNU format of LS_COLORS
+// such as "di=01;34:ln=01;36:*.png=01;35", or in the BSD format of LSCOLORS
+// such as "exfxcxdxbxegedabagacad". The styles of extensions are keyed by the
+// lower case extension including the dot, so the result can be used as
+// ExtensionStyles. The styles of directories and symlinks are keyed by "di"
+// and "ln". Entries that can't be parsed are left out.
+func ParseLSColors(env string) map[string]lipgloss.Style {
+	styles := map[string]lipgloss.Style{}
+	if env == "" {
+		return styles
+	}
+	if !strings.Contains(env, "=") {
+		return parseBSDColors(env)
+	}
+
+	for _, entry := range strings.Split(env, ":") {
+		name, codes, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		style, ok := parseSGR(codes)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "di" || name == "ln":
+			styles[name] = style
+		case strings.HasPrefix(name, "*.") && len(name) > 2:
+			styles[strings.ToLower(name[1:])] = style
+		}
+	}
+	return styles
+}
+
+// parseSGR turns the ";" separated SGR parameters of an LS_COLORS entry into
+// a style.
+func parseSGR(codes string) (lipgloss.Style, bool) {
+	style := lipgloss.NewStyle()
+	var params []int
+	for _, code := range strings.Split(codes, ";") {
+		if code == "" {
+			code = "0"
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			return style, false
+		}
+		params = append(params, n)
+	}
+
+	for i := 0; i < len(params); i++ {
+		switch n := params[i]; {
+		case n == 1:
+			style = style.Bold(true)
+		case n == 2:
+			style = style.Faint(true)
+		case n == 3:
+			style = style.Italic(true)
+		case n == 4:
+			style = style.Underline(true)
+		case n == 5:
+			style = style.Blink(true)
+		case n == 7:
+			style = style.Reverse(true)
+		case n == 9:
+			style = style.Strikethrough(true)
+		case n >= 30 && n <= 37:
+			style = style.Foreground(ansiColor(n - 30))
+		case n >= 90 && n <= 97:
+			style = style.Foreground(ansiColor(n - 90 + 8))
+		case n >= 40 && n <= 47:
+			style = style.Background(ansiColor(n - 40))
+		case n >= 100 && n <= 107:
+			style = style.Background(ansiColor(n - 100 + 8))
+		case n == 38 || n == 48:
+			// Extended colors are either 5;n from the 256 color palette or
+			// 2;r;g;b.
+			var color lipgloss.Color
+			switch {
+			case i+2 < len(params) && params[i+1] == 5:
+				color = ansiColor(params[i+2])
+				i += 2
+			case i+4 < len(params) && params[i+1] == 2:
+				color = lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", params[i+2]&0xff, params[i+3]&0xff, params[i+4]&0xff))
+				i += 4
+			default:
+				return style, false
+			}
+			if n == 38 {
+				style = style.Foreground(color)
+			} else {
+				style = style.Background(color)
+			}
+		}
+	}
+	return style, true
+}
+
+// parseBSDColors parses LSCOLORS, where each pair of letters sets the
+// foreground and background of one kind of entry. The first pair is for
+// directories and the second for symlinks. The letters a to h are the eight
+// ANSI colors, A to H their bold variants and x the default color.
+func parseBSDColors(env string) map[string]lipgloss.Style {
+	styles := map[string]lipgloss.Style{}
+	for i, name := range []string{"di", "ln"} {
+		if len(env) < 2*i+2 {
+			break
+		}
+		fg, bg := env[2*i], env[2*i+1]
+		style := lipgloss.NewStyle()
+		switch {
+		case fg >= 'a' && fg <= 'h':
+			style = style.Foreground(ansiColor(int(fg - 'a')))
+		case fg >= 'A' && fg <= 'H':
+			style = style.Foreground(ansiColor(int(fg - 'A'))).Bold(true)
+		}
+		switch {
+		case bg >= 'a' && bg <= 'h':
+			style = style.Background(ansiColor(int(bg - 'a')))
+		case bg >= 'A' && bg <= 'H':
+			style = style.Background(ansiColor(int(bg - 'A')))
+		}
+		styles[name] = style
+	}
+	return styles
+}
+
+func ansiColor(n int) lipgloss.Color {
+	return lipgloss.Color(strconv.Itoa(n))
+}
+
+// WithLSColors colors the entries like ls does, using LS_COLORS or, if that
+// isn't set, LSCOLORS. Directories and symlinks get the colors of "di" and
+// "ln", and files the colors of their extensions. It does nothing if colors
+// are disabled.
+func WithLSColors() Option {
+	return func(m *Model) {
+		if m.colorsDisabled {
+			return
+		}
+		env := os.Getenv("LS_COLORS")
+		if env == "" {
+			env = os.Getenv("LSCOLORS")
+		}
+		styles := ParseLSColors(env)
+		if style, ok := styles["di"]; ok {
+			m.Styles.Directory = style
+			delete(styles, "di")
+		}
+		if style, ok := styles["ln"]; ok {
+			m.Styles.Symlink = style
+			delete(styles, "ln")
+		}
+		if len(styles) > 0 {
+			m.ExtensionStyles = styles
+		}
+	}
+}
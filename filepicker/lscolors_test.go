@@ -0,0 +1,60 @@
+package filepicker
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSColors(t *testing.T) {
+	// The default of GNU dircolors, shortened.
+	styles := ParseLSColors("rs=0:di=01;34:ln=01;36:mh=00:pi=40;33:so=01;35:ex=01;32:" +
+		"*.tar=01;31:*.tgz=01;31:*.JPG=01;35:*.png=01;35:*.flac=00;36:*.mp3=00;36")
+
+	require.Contains(t, styles, "di")
+	assert.True(t, styles["di"].GetBold())
+	assert.Equal(t, lipgloss.Color("4"), styles["di"].GetForeground())
+	assert.Equal(t, lipgloss.Color("6"), styles["ln"].GetForeground())
+	assert.Equal(t, lipgloss.Color("1"), styles[".tar"].GetForeground())
+	// Extensions are keyed in lower case.
+	assert.Equal(t, lipgloss.Color("5"), styles[".jpg"].GetForeground())
+	assert.False(t, styles[".mp3"].GetBold())
+	assert.Equal(t, lipgloss.Color("6"), styles[".mp3"].GetForeground())
+	// Kinds of entries other than directories and symlinks are left out.
+	for _, name := range []string{"rs", "mh", "pi", "so", "ex"} {
+		assert.NotContains(t, styles, name)
+	}
+	assert.Len(t, styles, 8)
+}
+
+func TestParseLSColorsExtendedColors(t *testing.T) {
+	// As set by themes like vivid, with 256 and true colors.
+	styles := ParseLSColors("di=0;38;2;189;147;249:*.md=38;5;185;1:*.go=48;5;17;38;5;81:*.rs=91:*.bad=1;x:*.c=38;5")
+
+	assert.Equal(t, lipgloss.Color("#bd93f9"), styles["di"].GetForeground())
+	assert.Equal(t, lipgloss.Color("185"), styles[".md"].GetForeground())
+	assert.True(t, styles[".md"].GetBold())
+	assert.Equal(t, lipgloss.Color("17"), styles[".go"].GetBackground())
+	assert.Equal(t, lipgloss.Color("81"), styles[".go"].GetForeground())
+	assert.Equal(t, lipgloss.Color("9"), styles[".rs"].GetForeground())
+	// Entries that can't be parsed are left out.
+	assert.NotContains(t, styles, ".bad")
+	assert.NotContains(t, styles, ".c")
+}
+
+func TestParseLSColorsBSD(t *testing.T) {
+	// The default LSCOLORS of macOS.
+	styles := ParseLSColors("exfxcxdxbxegedabagacad")
+	require.Len(t, styles, 2)
+	assert.Equal(t, lipgloss.Color("4"), styles["di"].GetForeground())
+	assert.Equal(t, lipgloss.Color("5"), styles["ln"].GetForeground())
+	assert.Equal(t, lipgloss.NoColor{}, styles["di"].GetBackground())
+
+	styles = ParseLSColors("Gxfx")
+	assert.Equal(t, lipgloss.Color("6"), styles["di"].GetForeground())
+	assert.True(t, styles["di"].GetBold())
+
+	assert.Empty(t, ParseLSColors(""))
+}
@@ -0,0 +1,146 @@
+package filepicker
+
+// Messages holds the text the file picker shows to the user, so it can be
+// translated. Fields ending in a format verb are passed to fmt.Sprintf. The
+// help of the key bindings is set on the KeyMap instead.
+type Messages struct {
+	// EmptyDirectory is shown for a directory without entries, unless
+	// Model.EmptyMessage is set.
+	EmptyDirectory string
+	// Loading follows the spinner while a directory is read.
+	Loading string
+	// ErrorPrefix goes in front of errors.
+	ErrorPrefix string
+
+	// The prompts of the inputs for renaming, creating a directory and going
+	// to a path.
+	Rename       string
+	NewDirectory string
+	GoTo         string
+
+	// ConfirmDelete asks whether to delete the entry named by %s, and
+	// ConfirmDeleteMany whether to delete %d entries, the first few of which
+	// are named by %s. ConfirmMove and ConfirmMoveMany ask the same about
+	// moving cut entries into the current directory.
+	ConfirmDelete     string
+	ConfirmDeleteMany string
+	ConfirmMove       string
+	ConfirmMoveMany   string
+	// SetBookmark and JumpToBookmark ask for the letter of a bookmark, and
+	// Bookmarks leads the list of bookmarks. JumpToLetter asks for the first
+	// letter of the entry to jump to.
+	SetBookmark    string
+	JumpToBookmark string
+	Bookmarks      string
+	JumpToLetter   string
+
+	// Selected tells how many entries are marked in MultiSelect mode.
+	Selected string
+	// ScrollIndicator tells the first and last listed entry and the number of
+	// entries.
+	ScrollIndicator string
+	// TreeTruncated tells how many files a Recursive listing is cut off at.
+	TreeTruncated string
+
+	// UndoRename and UndoTrash describe a rename from the first %s to the
+	// second and a move of %s to the trash. Undone tells which of them was
+	// undone.
+	UndoRename string
+	UndoTrash  string
+	Undone     string
+
+	// Yanked and Cut tell which entries, joined into %s, the Paste key would
+	// copy or move.
+	Yanked string
+	Cut    string
+
+	// The labels of the details shown by the Inspect key.
+	InspectPath     string
+	InspectSize     string
+	InspectMode     string
+	InspectModified string
+	InspectOwner    string
+	InspectTarget   string
+
+	// The headers of the groups of GroupByType.
+	Directories string
+	Images      string
+	Documents   string
+	Other       string
+
+	// The names of the selection modes shown in the help of SelectMode.
+	SelectFiles string
+	SelectDirs  string
+	SelectBoth  string
+	SelectNone  string
+
+	// The errors of navigation and file operations, made with fmt.Errorf.
+	// NotADirectory and AlreadyExists name a path by %s and InvalidName a
+	// name by %q. OutsideRoot names a path and the root it isn't in,
+	// LinkOutsideRoot a symlink, its target and the root, and LinkLoop a
+	// symlink and the target that contains it.
+	NotADirectory   string
+	AlreadyExists   string
+	InvalidName     string
+	OutsideRoot     string
+	LinkOutsideRoot string
+	LinkLoop        string
+	// BatchFailed tells how many entries an operation failed on and joins
+	// their errors into %s.
+	BatchFailed string
+	// TrashDeleted says that the entry named by %s couldn't be moved to the
+	// trash and was deleted for good instead, wrapping why by %w.
+	// TrashDeleteFailed says that deleting it failed too, followed by the
+	// error of the trash as %v and of the deletion as %w.
+	TrashDeleted      string
+	TrashDeleteFailed string
+}
+
+// DefaultMessages are the English messages of the file picker.
+var DefaultMessages = Messages{
+	EmptyDirectory:    "Bummer. No Files Found.",
+	Loading:           "Loading...",
+	ErrorPrefix:       "",
+	Rename:            "rename: ",
+	NewDirectory:      "new directory: ",
+	GoTo:              "go to: ",
+	ConfirmDelete:     "Delete %s? (y/n)",
+	ConfirmDeleteMany: "Delete %d entries (%s)? (y/n)",
+	ConfirmMove:       "Move %s here? (y/n)",
+	ConfirmMoveMany:   "Move %d entries (%s) here? (y/n)",
+	SetBookmark:       "bookmark: press a letter",
+	JumpToBookmark:    "jump to bookmark: press a letter",
+	Bookmarks:         "bookmarks: ",
+	JumpToLetter:      "jump to: press a letter",
+	Selected:          "(%d selected)",
+	ScrollIndicator:   "showing %d-%d of %d",
+	TreeTruncated:     "only the first %d files are listed",
+	UndoRename:        "renaming %s to %s",
+	UndoTrash:         "moving %s to the trash",
+	Undone:            "undid %s",
+	Yanked:            "yanked: %s",
+	Cut:               "cut: %s",
+	InspectPath:       "path",
+	InspectSize:       "size",
+	InspectMode:       "mode",
+	InspectModified:   "modified",
+	InspectOwner:      "owner",
+	InspectTarget:     "target",
+	Directories:       "Directories",
+	Images:            "Images",
+	Documents:         "Documents",
+	Other:             "Other",
+	SelectFiles:       "files",
+	SelectDirs:        "dirs",
+	SelectBoth:        "both",
+	SelectNone:        "none",
+	NotADirectory:     "%s is not a directory",
+	AlreadyExists:     "%s already exists",
+	InvalidName:       "invalid name %q",
+	OutsideRoot:       "%s is outside of %s",
+	LinkOutsideRoot:   "%s links to %s, which is outside of %s",
+	LinkLoop:          "%s links to %s, which contains it",
+	BatchFailed:       "%d entries failed: %s",
+	TrashDeleted:      "moving %s to the trash failed, deleted it instead: %w",
+	TrashDeleteFailed: "moving %s to the trash failed: %v, and deleting it failed too: %w",
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package filepicker
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice reports whether err is the error of a rename to another file
+// system.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, which MoveFileEx fails with
+// when the destination is on another drive.
+const errorNotSameDevice syscall.Errno = 0x11
+
+// isCrossDevice reports whether err is the error of a rename to another
+// volume.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
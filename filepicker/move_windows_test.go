@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCrossDevice(t *testing.T) {
+	// os.Rename wraps the error of MoveFileEx in a LinkError.
+	err := &os.LinkError{Op: "rename", Old: `C:\a.txt`, New: `D:\a.txt`, Err: syscall.Errno(0x11)}
+	assert.True(t, isCrossDevice(err))
+	err.Err = syscall.ERROR_ACCESS_DENIED
+	assert.False(t, isCrossDevice(err))
+	assert.False(t, isCrossDevice(nil))
+}
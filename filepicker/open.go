@@ -0,0 +1,32 @@
+package filepicker
+
+import "os/exec"
+
+// Launcher opens files with other applications.
+type Launcher interface {
+	Open(path string) error
+}
+
+// SystemLauncher is the launcher used by OpenWithDefault and the OpenExternal
+// key. It defaults to the opener of the platform. Programs that open files
+// their own way, such as in an editor, set it to a Launcher of their own.
+var SystemLauncher Launcher = commandLauncher{}
+
+// OpenWithDefault opens path with the default application for it, using the
+// SystemLauncher.
+func OpenWithDefault(path string) error {
+	return SystemLauncher.Open(path)
+}
+
+// commandLauncher opens files with the opener of the platform: open on
+// macOS, rundll32 on Windows and xdg-open elsewhere.
+type commandLauncher struct{}
+
+func (commandLauncher) Open(path string) error {
+	name, args := openCommand(path)
+	return runCommand(exec.Command(name, args...))
+}
+
+// runCommand runs the opener, and is swapped out by the tests to see what it
+// would run.
+var runCommand = (*exec.Cmd).Run
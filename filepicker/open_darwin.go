@@ -0,0 +1,8 @@
+//go:build darwin
+// +build darwin
+
+package filepicker
+
+func openCommand(path string) (string, []string) {
+	return "open", []string{path}
+}
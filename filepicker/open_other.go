@@ -0,0 +1,8 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package filepicker
+
+func openCommand(path string) (string, []string) {
+	return "xdg-open", []string{path}
+}
@@ -0,0 +1,46 @@
+package filepicker
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLauncher records the paths it is asked to open.
+type stubLauncher struct {
+	opened []string
+}
+
+func (l *stubLauncher) Open(path string) error {
+	l.opened = append(l.opened, path)
+	return nil
+}
+
+func TestOpenExternalUsesSystemLauncher(t *testing.T) {
+	launcher := &stubLauncher{}
+	defer func(l Launcher) { SystemLauncher = l }(SystemLauncher)
+	SystemLauncher = launcher
+
+	dir := makeTree(t, "a.txt")
+	m := newTestModel(t, dir)
+	press(m, "o")
+	assert.Equal(t, []string{filepath.Join(dir, "a.txt")}, launcher.opened)
+}
+
+func TestCommandLauncherPassesPathAsOneArgument(t *testing.T) {
+	var args []string
+	defer func(run func(*exec.Cmd) error) { runCommand = run }(runCommand)
+	runCommand = func(cmd *exec.Cmd) error {
+		args = cmd.Args
+		return nil
+	}
+
+	path := filepath.Join(t.TempDir(), "a&calc.exe.txt")
+	require.NoError(t, commandLauncher{}.Open(path))
+	name, want := openCommand(path)
+	assert.Equal(t, append([]string{name}, want...), args)
+	assert.Equal(t, path, args[len(args)-1])
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+// openCommand has the file protocol handler of the shell open path, which
+// gets it as it is. Going through start in cmd would have cmd parse the path,
+// and run what follows a & in a name such as "a&calc.exe.txt".
+func openCommand(path string) (string, []string) {
+	return "rundll32", []string{"url.dll,FileProtocolHandler", path}
+}
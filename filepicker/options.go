@@ -0,0 +1,48 @@
+package filepicker
+
+// Option configures a Model created by NewWithOptions.
+type Option func(*Model)
+
+// WithHeight sets a fixed height and turns off AutoHeight.
+func WithHeight(height int) Option {
+	return func(m *Model) {
+		m.Height = height
+		m.AutoHeight = false
+	}
+}
+
+// WithWidth sets the width of the picker.
+func WithWidth(width int) Option {
+	return func(m *Model) {
+		m.Width = width
+	}
+}
+
+// WithPath sets the directory the picker starts in.
+func WithPath(path string) Option {
+	return func(m *Model) {
+		m.CurrentDirectory = path
+		m.PathUI = path
+	}
+}
+
+// WithShowHidden sets whether hidden files are shown.
+func WithShowHidden(show bool) Option {
+	return func(m *Model) {
+		m.ShowHidden = show
+	}
+}
+
+// WithAllowedTypes sets the file types that can be selected.
+func WithAllowedTypes(types ...string) Option {
+	return func(m *Model) {
+		m.AllowedTypes = types
+	}
+}
+
+// WithDirAllowed sets whether directories can be selected.
+func WithDirAllowed(allowed bool) Option {
+	return func(m *Model) {
+		m.DirAllowed = allowed
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+// +build !unix
+
+package filepicker
+
+import "os"
+
+// fileOwner returns no owner, since files have no user and group ids on this
+// platform.
+func fileOwner(info os.FileInfo) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,36 @@
+package filepicker
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath returns p as an absolute path. A leading "~" stands for the home
+// directory of the current user and "~name" for the one of the user name.
+// Relative paths are taken relative to the working directory.
+func ExpandPath(p string) (string, error) {
+	if strings.HasPrefix(p, "~") {
+		name, rest := p[1:], ""
+		if i := strings.IndexAny(name, `/`+string(filepath.Separator)); i >= 0 {
+			name, rest = name[:i], name[i:]
+		}
+
+		var home string
+		if name == "" {
+			var err error
+			if home, err = os.UserHomeDir(); err != nil {
+				return "", err
+			}
+		} else {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			home = u.HomeDir
+		}
+		p = filepath.Join(home, rest)
+	}
+	return filepath.Abs(p)
+}
@@ -0,0 +1,58 @@
+package filepicker
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	// os.UserHomeDir reads USERPROFILE on Windows and HOME elsewhere.
+	if runtime.GOOS == "windows" {
+		t.Setenv("USERPROFILE", home)
+	} else {
+		t.Setenv("HOME", home)
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	tests := []struct {
+		path, want string
+	}{
+		{"~", home},
+		{"~/docs", filepath.Join(home, "docs")},
+		{"docs/a.txt", filepath.Join(wd, "docs", "a.txt")},
+		{".", wd},
+		{home, home},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct{ path, want string }{`~\docs`, filepath.Join(home, "docs")})
+	}
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+}
+
+func TestExpandPathOfUser(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("user names include the domain on Windows")
+	}
+	u, err := user.Current()
+	if err != nil {
+		t.Skip("no current user:", err)
+	}
+	got, err := ExpandPath("~" + u.Username + "/docs")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(u.HomeDir, "docs"), got)
+
+	_, err = ExpandPath("~no-such-user-hopefully/docs")
+	assert.Error(t, err)
+}
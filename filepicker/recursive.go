@@ -0,0 +1,117 @@
+package filepicker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultRecursiveDepth is how many levels below the current directory
+	// are listed in Recursive mode if RecursiveDepth is zero.
+	defaultRecursiveDepth = 8
+
+	// maxTreeEntries is the most files listed in Recursive mode. Anything
+	// beyond it is left out, and a notice says so.
+	maxTreeEntries = 10000
+)
+
+// errStopWalk ends the walk of readTree once it has found enough files.
+var errStopWalk = errors.New("stop walking")
+
+// treeMsg is sent instead of a readDirMsg in Recursive mode. truncated is set
+// if there were more than maxTreeEntries files.
+type treeMsg struct {
+	gen       int
+	entries   []os.DirEntry
+	truncated bool
+}
+
+// treeEntry is a file below the listed directory. Its name is the path
+// relative to that directory, so the listing shows where the file is and
+// joining it with the directory gives the full path.
+type treeEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e treeEntry) Name() string {
+	return e.name
+}
+
+// readTree returns the files below root, at most depth levels deep and
+// without hidden files and directories unless showHidden is set. It stops
+// after maxTreeEntries files and reports whether there were more.
+func readTree(fsys fs.FS, root string, showHidden bool, depth int) ([]os.DirEntry, bool, error) {
+	var entries []os.DirEntry
+	var truncated bool
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory itself has to be readable, the ones below it are
+			// skipped if they aren't.
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+
+		var rel string
+		var hidden bool
+		if fsys != nil {
+			rel = strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+			if root == "." {
+				rel = p
+			}
+			hidden = strings.HasPrefix(path.Base(p), ".")
+		} else {
+			rel, _ = filepath.Rel(root, p)
+			hidden, _ = IsHidden(p)
+		}
+		if hidden && !showHidden {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if strings.Count(filepath.ToSlash(rel), "/") >= depth-1 {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if len(entries) == maxTreeEntries {
+			truncated = true
+			return errStopWalk
+		}
+		entries = append(entries, treeEntry{DirEntry: d, name: rel})
+		return nil
+	}
+
+	var err error
+	if fsys != nil {
+		err = fs.WalkDir(fsys, root, walk)
+	} else {
+		err = filepath.WalkDir(root, walk)
+	}
+	if err == errStopWalk {
+		err = nil
+	}
+	return entries, truncated, err
+}
+
+// treeNoticeView returns a notice that not all files are listed in Recursive
+// mode, or nothing if they are.
+func (m Model) treeNoticeView() string {
+	if !m.Recursive || !m.treeTruncated {
+		return ""
+	}
+	return m.Styles.ScrollIndicator.Render(fmt.Sprintf(m.Messages.TreeTruncated, maxTreeEntries)) + "\n"
+}
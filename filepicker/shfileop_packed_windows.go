@@ -0,0 +1,24 @@
+//go:build windows && (386 || arm)
+// +build windows
+// +build 386 arm
+
+package filepicker
+
+// shFileOpStruct is SHFILEOPSTRUCTW, which is packed on 32-bit Windows. The
+// fields after fFlags are byte arrays so that Go doesn't pad them to their
+// alignment, and are only read through aborted.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted [4]byte
+	hNameMappings         [4]byte
+	lpszProgressTitle     [4]byte
+}
+
+// aborted reports whether the user aborted the operation.
+func (op *shFileOpStruct) aborted() bool {
+	return op.fAnyOperationsAborted != [4]byte{}
+}
@@ -0,0 +1,22 @@
+//go:build windows && !386 && !arm
+// +build windows,!386,!arm
+
+package filepicker
+
+// shFileOpStruct is SHFILEOPSTRUCTW, which has its natural alignment on 64-bit
+// Windows.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+// aborted reports whether the user aborted the operation.
+func (op *shFileOpStruct) aborted() bool {
+	return op.fAnyOperationsAborted != 0
+}
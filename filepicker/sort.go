@@ -0,0 +1,108 @@
+package filepicker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SortMode defines the order in which entries are listed.
+type SortMode int
+
+const (
+	// SortByName lists entries alphabetically.
+	SortByName SortMode = iota
+	// SortBySize lists the largest entries first.
+	SortBySize
+	// SortByModTime lists the most recently modified entries first.
+	SortByModTime
+	// SortByExtension lists entries alphabetically by extension, then by name.
+	SortByExtension
+)
+
+// String returns a short, human readable name for the sort mode.
+func (s SortMode) String() string {
+	switch s {
+	case SortBySize:
+		return "size"
+	case SortByModTime:
+		return "modified"
+	case SortByExtension:
+		return "extension"
+	default:
+		return "name"
+	}
+}
+
+// next returns the sort mode that follows s when cycling through the modes.
+func (s SortMode) next() SortMode {
+	return (s + 1) % (SortByExtension + 1)
+}
+
+// sortEntries sorts the entries in place according to mode. Directories are
+// grouped before files unless mixDirs is set, and reverse flips the order
+// within each group.
+func sortEntries(entries []os.DirEntry, mode SortMode, reverse, mixDirs bool) {
+	// Only stat the entries when the mode actually needs the file info.
+	infos := make(map[string]os.FileInfo, len(entries))
+	if mode == SortBySize || mode == SortByModTime {
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil {
+				infos[entry.Name()] = info
+			}
+		}
+	}
+
+	// less compares two entries of the same kind in ascending order.
+	less := func(a, b os.DirEntry) bool {
+		switch mode {
+		case SortBySize:
+			sa, sb := size(infos[a.Name()]), size(infos[b.Name()])
+			if sa != sb {
+				return sa > sb
+			}
+		case SortByModTime:
+			ia, ib := infos[a.Name()], infos[b.Name()]
+			if ia != nil && ib != nil && !ia.ModTime().Equal(ib.ModTime()) {
+				return ia.ModTime().After(ib.ModTime())
+			}
+		case SortByExtension:
+			ea := strings.ToLower(filepath.Ext(a.Name()))
+			eb := strings.ToLower(filepath.Ext(b.Name()))
+			if ea != eb {
+				return ea < eb
+			}
+		}
+		return a.Name() < b.Name()
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !mixDirs && a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		if reverse {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// DirsFirst wraps less so that directories are listed before files, and less
+// only compares entries of the same kind. Use it with Model.SortFunc.
+func DirsFirst(less func(a, b os.DirEntry) bool) func(a, b os.DirEntry) bool {
+	return func(a, b os.DirEntry) bool {
+		if a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		return less(a, b)
+	}
+}
+
+func size(info os.FileInfo) int64 {
+	if info == nil {
+		return 0
+	}
+	return info.Size()
+}
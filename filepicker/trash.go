@@ -0,0 +1,15 @@
+package filepicker
+
+import "errors"
+
+// errTrashUnavailable is wrapped by the errors of trash when there is no trash
+// to move to at all, as opposed to one entry that can't be moved there.
+var errTrashUnavailable = errors.New("no trash available")
+
+// TrashFile moves the file or directory at path to the trash of the platform,
+// from where it can be restored: the Trash of the user on macOS, the Recycle
+// Bin on Windows and the trash of the XDG trash specification elsewhere.
+func TrashFile(path string) error {
+	_, _, err := trash(path)
+	return err
+}
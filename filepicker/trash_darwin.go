@@ -0,0 +1,28 @@
+//go:build darwin
+// +build darwin
+
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// trash moves path into ~/.Trash, renamed if the name is taken there, and
+// returns where it went.
+func trash(path string) (string, string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", errTrashUnavailable, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	trashed := AvailablePath(filepath.Join(home, ".Trash", filepath.Base(abs)))
+	if err := os.Rename(abs, trashed); err != nil {
+		return "", "", err
+	}
+	return trashed, "", nil
+}
@@ -0,0 +1,128 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package filepicker
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// trashInfoTime is the layout of the DeletionDate of a .trashinfo file.
+const trashInfoTime = "2006-01-02T15:04:05"
+
+// trash moves path into a trash of the XDG trash specification and returns
+// the paths of the entry and its .trashinfo file there. Entries go into the
+// home trash, unless that is on another file system, in which case they go
+// into $topdir/.Trash-$uid at the top of their own.
+func trash(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	home, err := trashDir()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", errTrashUnavailable, err)
+	}
+	trashed, info, err := trashInto(home, abs, abs)
+	if !errors.Is(err, syscall.EXDEV) {
+		return trashed, info, err
+	}
+
+	top, err := topDir(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", errTrashUnavailable, err)
+	}
+	// The path in the .trashinfo file of a $topdir trash is relative to it.
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return "", "", err
+	}
+	return trashInto(filepath.Join(top, fmt.Sprintf(".Trash-%d", os.Getuid())), abs, rel)
+}
+
+// trashInto moves the entry at abs into the trash at dir: the entry goes into
+// dir/files and a .trashinfo file telling that it came from origin into
+// dir/info, under a name that is free in both.
+func trashInto(dir, abs, origin string) (string, string, error) {
+	files, info := filepath.Join(dir, "files"), filepath.Join(dir, "info")
+	for _, d := range []string{files, info} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return "", "", fmt.Errorf("%w: %v", errTrashUnavailable, err)
+		}
+	}
+
+	// Creating the .trashinfo file exclusively reserves the name.
+	base := filepath.Base(abs)
+	name := base
+	var f *os.File
+	var err error
+	for i := 1; ; i++ {
+		f, err = os.OpenFile(filepath.Join(info, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			if _, err := os.Lstat(filepath.Join(files, name)); os.IsNotExist(err) {
+				break
+			}
+			// A file without info is in the way, so give the name back.
+			f.Close()
+			os.Remove(f.Name())
+		} else if !os.IsExist(err) {
+			return "", "", err
+		}
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+	infoPath := f.Name()
+	_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: origin}).EscapedPath(), time.Now().Format(trashInfoTime))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(abs, filepath.Join(files, name))
+	}
+	if err != nil {
+		os.Remove(infoPath)
+		return "", "", err
+	}
+	return filepath.Join(files, name), infoPath, nil
+}
+
+// topDir returns the mount point of the file system path is on, the topmost
+// directory above it on the same device.
+func topDir(path string) (string, error) {
+	dev, err := device(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		parentDev, err := device(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
+
+// trashDir returns the home trash, $XDG_DATA_HOME/Trash or
+// ~/.local/share/Trash.
+func trashDir() (string, error) {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
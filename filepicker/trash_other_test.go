@@ -0,0 +1,84 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package filepicker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashMovesIntoHomeTrash(t *testing.T) {
+	data := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", data)
+	dir := makeTree(t, "a.txt")
+	path := filepath.Join(dir, "a.txt")
+
+	to, info, err := trash(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(data, "Trash", "files", "a.txt"), to)
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, to)
+	content, err := os.ReadFile(info)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Path="+path+"\n")
+
+	// A second entry of the same name gets a name of its own.
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	to, _, err = trash(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(data, "Trash", "files", "a.txt.1"), to)
+}
+
+func TestRemoveWithoutTrashDeletesWithWarning(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", "")
+	dir := makeTree(t, "a.txt")
+	m := newTestModel(t, dir)
+	m.UseTrash = true
+
+	m = run(m, m.remove([]string{filepath.Join(dir, "a.txt")}, false))
+	assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+	require.Error(t, m.err)
+	assert.Contains(t, m.err.Error(), "deleted it instead")
+}
+
+func TestRemoveLeavesEntryThatCantBeTrashed(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := makeTree(t, "a.txt", "sub/")
+	m := newTestModel(t, dir)
+	m.UseTrash = true
+
+	// A path that is gone can't be moved to the trash, which must not make
+	// the picker delete anything else.
+	m = run(m, m.remove([]string{filepath.Join(dir, "missing"), filepath.Join(dir, "a.txt")}, true))
+	require.Error(t, m.err)
+	assert.NotContains(t, m.err.Error(), "deleted it instead")
+	assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+	assert.DirExists(t, filepath.Join(dir, "sub"))
+}
+
+func TestUndoTrash(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := makeTree(t, "a.txt", "b.txt", "c.txt")
+	m := newTestModel(t, dir)
+	m.UseTrash = true
+	m.MultiSelect = true
+
+	m = press(m, " ", "down", " ", "d", "y")
+	require.Equal(t, []string{"c.txt"}, names(m))
+	m = press(m, "u")
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, names(m))
+	assert.Equal(t, "undid moving a.txt, b.txt to the trash", m.notice)
+	content, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", string(content))
+	// The trash doesn't keep records of entries that are back.
+	infos, err := os.ReadDir(filepath.Join(os.Getenv("XDG_DATA_HOME"), "Trash", "info"))
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+}
@@ -0,0 +1,53 @@
+//go:build windows
+// +build windows
+
+package filepicker
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// The operation and flags of SHFileOperationW that move a file to the Recycle
+// Bin without asking or showing progress.
+const (
+	foDelete          = 0x3
+	fofSilent         = 0x4
+	fofNoConfirmation = 0x10
+	fofAllowUndo      = 0x40
+	fofNoErrorUI      = 0x400
+)
+
+var shFileOperation = syscall.NewLazyDLL("shell32.dll").NewProc("SHFileOperationW")
+
+// trash moves path to the Recycle Bin with SHFileOperationW. Where it went is
+// up to the Recycle Bin, so it returns no path and the move can't be undone.
+func trash(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	// pFrom is a list of paths that ends with an empty one.
+	from, err := syscall.UTF16FromString(abs)
+	if err != nil {
+		return "", "", err
+	}
+	from = append(from, 0)
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	if err := shFileOperation.Find(); err != nil {
+		return "", "", fmt.Errorf("%w: %v", errTrashUnavailable, err)
+	}
+	if r, _, _ := shFileOperation.Call(uintptr(unsafe.Pointer(&op))); r != 0 {
+		return "", "", fmt.Errorf("moving %s to the Recycle Bin failed with code %#x", abs, r)
+	}
+	if op.aborted() {
+		return "", "", fmt.Errorf("moving %s to the Recycle Bin was aborted", abs)
+	}
+	return "", "", nil
+}
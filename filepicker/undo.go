@@ -0,0 +1,86 @@
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxUndo is how many file operations are remembered for the Undo key.
+const maxUndo = 20
+
+// fileOp is the move of one entry that is undone by moving the entry at to
+// back to from. info is the .trashinfo file of an entry moved to the trash,
+// which is removed along with it.
+type fileOp struct {
+	from, to string
+	info     string
+}
+
+// undoEntry is an operation the Undo key reverts at once, made up of the
+// moves of all the entries it applied to. desc describes it to the user.
+type undoEntry struct {
+	desc string
+	ops  []fileOp
+}
+
+// fileOpMsg is sent when a file operation that can be undone succeeded. read
+// re-reads the directory afterwards.
+type fileOpMsg struct {
+	entry undoEntry
+	read  tea.Cmd
+}
+
+// undoneMsg is sent when the operation described by desc was undone.
+type undoneMsg struct {
+	desc string
+}
+
+// pushUndo remembers entry for the Undo key, forgetting the oldest operation
+// if there are more than maxUndo.
+func (m *Model) pushUndo(entry undoEntry) {
+	m.undoStack = append(m.undoStack, entry)
+	if len(m.undoStack) > maxUndo {
+		m.undoStack = m.undoStack[len(m.undoStack)-maxUndo:]
+	}
+}
+
+// undo returns a command that reverts the last file operation and re-reads
+// the current directory, or nil if there is nothing to undo. It refuses to
+// overwrite entries that took the place of the original ones.
+func (m *Model) undo() tea.Cmd {
+	if len(m.undoStack) == 0 {
+		return nil
+	}
+	entry := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	// Put the cursor on the restored entry if it is back in this directory.
+	if from := entry.ops[0].from; filepath.Dir(from) == m.CurrentDirectory {
+		m.focusName = filepath.Base(from)
+	}
+	messages := m.Messages
+	read := m.readDir()
+	return func() tea.Msg {
+		var errs []error
+		for i := len(entry.ops) - 1; i >= 0; i-- {
+			op := entry.ops[i]
+			if _, err := os.Lstat(op.from); err == nil {
+				errs = append(errs, fmt.Errorf(messages.AlreadyExists, op.from))
+				continue
+			}
+			if err := os.Rename(op.to, op.from); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if op.info != "" {
+				os.Remove(op.info)
+			}
+		}
+		if err := joinErrors(errs, messages); err != nil {
+			return failed(read, err)
+		}
+		return tea.BatchMsg{read, func() tea.Msg { return undoneMsg{entry.desc} }}
+	}
+}
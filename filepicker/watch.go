@@ -0,0 +1,113 @@
+package filepicker
+
+import (
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Changes that arrive within watchDebounce of each other are reported as one,
+// so writing a file doesn't re-read the directory several times.
+const watchDebounce = 100 * time.Millisecond
+
+// dirChangedMsg is sent when an entry of the watched directory dir was
+// created, removed, renamed or written to. dir is empty if the watcher failed
+// and the change is not known.
+type dirChangedMsg struct {
+	dir string
+}
+
+// dirWatcher watches the current directory for WatchDirectory. It is shared by
+// all copies of the Model, so moving to another directory re-points it.
+type dirWatcher struct {
+	watcher *fsnotify.Watcher
+	dir     string
+}
+
+// watch makes sure the current directory is watched if WatchDirectory is set.
+// The returned command waits for the first change when the watcher has just
+// been started; after that, waitForChange is issued for each dirChangedMsg.
+func (m *Model) watch() tea.Cmd {
+	// Only the local disk can be watched.
+	if !m.WatchDirectory || m.FileSystem != nil {
+		return nil
+	}
+	if m.watcher == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return func() tea.Msg { return errorMsg{err} }
+		}
+		m.watcher = &dirWatcher{watcher: w}
+		if err := m.watcher.repoint(m.CurrentDirectory); err != nil {
+			return func() tea.Msg { return errorMsg{err} }
+		}
+		return m.watcher.waitForChange()
+	}
+	if err := m.watcher.repoint(m.CurrentDirectory); err != nil {
+		return func() tea.Msg { return errorMsg{err} }
+	}
+	return nil
+}
+
+// repoint makes the watcher watch dir instead of the previous directory.
+func (d *dirWatcher) repoint(dir string) error {
+	if d.dir == dir {
+		return nil
+	}
+	if d.dir != "" {
+		_ = d.watcher.Remove(d.dir)
+	}
+	d.dir = ""
+	if err := d.watcher.Add(dir); err != nil {
+		return err
+	}
+	d.dir = dir
+	return nil
+}
+
+// waitForChange returns a command that blocks until the watched directory
+// changes. It returns nil once the watcher has been closed.
+func (d *dirWatcher) waitForChange() tea.Cmd {
+	w := d.watcher
+	return func() tea.Msg {
+		var dir string
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			dir = filepath.Dir(e.Name)
+		case _, ok := <-w.Errors:
+			// An error means events may have been lost, so the directory is
+			// re-read anyway.
+			if !ok {
+				return nil
+			}
+		}
+
+		timer := time.NewTimer(watchDebounce)
+		defer timer.Stop()
+		for {
+			select {
+			case e, ok := <-w.Events:
+				if !ok {
+					return nil
+				}
+				dir = filepath.Dir(e.Name)
+			case <-timer.C:
+				return dirChangedMsg{dir: dir}
+			}
+		}
+	}
+}
+
+// Close stops watching the current directory. A parent model should call it
+// once the program has quit if WatchDirectory is set.
+func (m Model) Close() error {
+	if m.watcher == nil {
+		return nil
+	}
+	return m.watcher.watcher.Close()
+}
@@ -0,0 +1,115 @@
+package filepicker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// yank puts the marked entries, or the entry under the cursor if none are
+// marked, on the internal clipboard, to be copied into another directory with
+// the Paste key, or moved if cut is true. The marks are cleared.
+func (m *Model) yank(cut bool) {
+	paths := m.targets()
+	if len(paths) == 0 || m.FileSystem != nil {
+		return
+	}
+	m.yanked = paths
+	m.yankCut = cut
+	m.clearSelection()
+}
+
+// paste returns a command that copies or moves the yanked entries into the
+// current directory and re-reads it, or nil if nothing is yanked. Entries
+// keep their names unless these are taken, in which case AvailablePath picks
+// new ones. Moved entries are taken off the clipboard, copied ones stay on it.
+// Entries that fail don't stop the others, and their errors are reported
+// together.
+func (m *Model) paste() tea.Cmd {
+	if len(m.yanked) == 0 || m.FileSystem != nil {
+		return nil
+	}
+	srcs, cut := m.yanked, m.yankCut
+	if cut {
+		m.yanked = nil
+	}
+	dir, messages := m.CurrentDirectory, m.Messages
+	m.focusName = filepath.Base(srcs[0])
+	read := m.readDir()
+	return func() tea.Msg {
+		var errs []error
+		for _, src := range srcs {
+			// Moving an entry into the directory it is in leaves it alone.
+			if cut && filepath.Dir(src) == dir {
+				continue
+			}
+			dst := AvailablePath(filepath.Join(dir, filepath.Base(src)))
+			var err error
+			if cut {
+				err = moveEntry(src, dst)
+			} else {
+				err = copyEntry(src, dst)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := joinErrors(errs, messages); err != nil {
+			return failed(read, err)
+		}
+		return read()
+	}
+}
+
+// copyEntry copies the file, directory or symlink at src to dst.
+func copyEntry(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	case info.IsDir():
+		return CopyDir(src, dst)
+	default:
+		return CopyFile(src, dst)
+	}
+}
+
+// moveEntry renames src to dst. Renaming doesn't work across file systems or
+// drives, so there the entry is copied and the original removed afterwards.
+func moveEntry(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !isCrossDevice(err) {
+		return err
+	}
+	if err := copyEntry(src, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// clipboardView returns the line telling what the Paste key would copy or
+// move, or an empty string if nothing is yanked.
+func (m Model) clipboardView() string {
+	if len(m.yanked) == 0 {
+		return ""
+	}
+	names := make([]string, len(m.yanked))
+	for i, path := range m.yanked {
+		names[i] = filepath.Base(path)
+	}
+	format := m.Messages.Yanked
+	if m.yankCut {
+		format = m.Messages.Cut
+	}
+	return m.Styles.ScrollIndicator.Render(fmt.Sprintf(format, strings.Join(names, ", "))) + "\n"
+}
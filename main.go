@@ -1,11 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	"github.com/nguyendhst/copyfile/filepicker"
@@ -15,18 +14,8 @@ import (
 )
 
 type model struct {
-	filepicker   filepicker.Model
-	selectedFile string
-	quitting     bool
-}
-
-type path struct {
-	absolute bool
-	truePath string
-}
-
-func NewPath(x string) path {
-	return path{absolute: _isAbsolutePath(x), truePath: _truePath(x)}
+	filepicker filepicker.Model
+	quitting   bool
 }
 
 func (m model) Init() tea.Cmd {
@@ -37,21 +26,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "ctrl+c", "q":
+		case "ctrl+c":
 			m.quitting = true
 			return m, tea.Quit
+		case "q":
+			if !m.filepicker.InputActive() {
+				m.quitting = true
+				return m, tea.Quit
+			}
 		}
 	}
 
 	var cmd tea.Cmd
 	m.filepicker, cmd = m.filepicker.Update(msg)
-
-	// Did the user select a file?
-	if didSelect, path := m.filepicker.DidSelectFile(msg); didSelect {
-		// Get the path of the selected file.
-		m.selectedFile = path
-	}
-
 	return m, cmd
 }
 
@@ -61,62 +48,223 @@ func (m model) View() string {
 	}
 	var s strings.Builder
 	s.WriteString("\n  ")
-	if m.selectedFile == "" {
+	if selected, _ := m.filepicker.SelectedFile(); selected == "" {
 		s.WriteString("Pick a file:")
 	} else {
-		s.WriteString("Selected file: " + m.filepicker.Styles.Selected.Render(m.selectedFile))
+		s.WriteString("Selected file: " + m.filepicker.Styles.Selected.Render(selected))
 	}
 	s.WriteString("\n\n" + m.filepicker.View() + "\n")
 	return s.String()
 }
 
+// The exit codes besides 0, which means the files were copied, tell scripts
+// that nothing was picked or that something failed.
+const (
+	exitNoSelection = 1
+	exitFailed      = 2
+)
+
 // TODO: add a flag to show hidden files
 func main() {
+	multi := flag.Bool("multi", false, "mark several files with space and copy all of them")
+	dest := flag.String("dest", "", "directory or file name to copy to (default: the current directory)")
+	var dryRun bool
+	flag.BoolVar(&dryRun, "n", false, "print what would be copied without copying")
+	flag.BoolVar(&dryRun, "dry-run", false, "same as -n")
+	printPaths := flag.Bool("print", false, "print the picked paths to stdout instead of copying, e.g. for cd \"$(copyfile -print)\"")
+	typeList := flag.String("types", "", "comma separated extensions of the files that can be picked, e.g. .go,.mod")
+	dirs := flag.Bool("dirs", false, "allow picking directories, which are copied with everything in them")
+	args := parseArgs()
 
-	if runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
-		fmt.Println("Sorry, this program is not supported on " + runtime.GOOS + ".")
-		return
+	types, err := parseTypes(*typeList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\n  Invalid types: "+err.Error()+"\n")
+		os.Exit(exitFailed)
 	}
 
-	path := ""
-	if len(os.Args) > 1 {
-		path = os.Args[1]
-	} else {
+	if *dest == "" && len(args) > 1 {
+		*dest = args[1]
+	}
+	if *dest == "" {
+		*dest = "."
+	}
+
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
 		path, _ = os.Getwd()
 	}
 
-	p := NewPath(path)
+	path, err = filepicker.ExpandPath(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\n  Invalid path: "+err.Error()+"\n")
+		os.Exit(exitFailed)
+	}
 
-	fp := filepicker.NewWithConfig(10, goterm.Width()-2, p.truePath)
+	fp := filepicker.NewWithConfig(10, goterm.Width()-2, path)
+	fp.MultiSelect = *multi
+	// Space marks files in MultiSelect mode, so enter has to finish the selection.
+	fp.SelectOnEnter = *multi
+	// A printed directory can be used with cd.
+	fp.DirAllowed = *printPaths || *dirs
+	fp.AllowedTypes = types
+	// View renders a blank line, the prompt and another blank line above the picker.
+	fp.OffsetY = 3
 
 	m := model{
 		filepicker: fp,
 	}
-	tm, _ := tea.NewProgram(&m, tea.WithOutput(os.Stderr)).Run()
+	tm, err := tea.NewProgram(&m, tea.WithOutput(os.Stderr), tea.WithMouseCellMotion()).Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\n  "+err.Error()+"\n")
+		os.Exit(exitFailed)
+	}
 	mm := tm.(model)
 
-	if mm.selectedFile != "" {
-		if runtime.GOOS == "darwin" {
-			exec.Command("cp", mm.selectedFile, ".").Run()
-		} else {
-			exec.Command("copy", mm.selectedFile, ".").Run()
+	selected, err := mm.filepicker.SelectedFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\n  Selection failed: "+err.Error()+"\n")
+		os.Exit(exitFailed)
+	}
+
+	// In MultiSelect mode only the marked files count, the entry under the
+	// cursor is marked by the picker itself if nothing else is.
+	files := mm.filepicker.SelectedFiles()
+	if !*multi && selected != "" {
+		files = append(files, selected)
+	}
+	if len(files) == 0 {
+		os.Exit(exitNoSelection)
+	}
+	if *printPaths {
+		// Only the paths go to stdout, the picker is drawn on stderr.
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		return
+	}
+	for _, file := range files {
+		if dryRun {
+			// Only tell where the file would go, without creating anything.
+			_, err := os.Stat(file)
+			var dst string
+			if err == nil {
+				dst, err = destination(file, *dest, len(files) > 1, false)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "\n  Dry run failed: "+err.Error()+"\n")
+				os.Exit(exitFailed)
+			}
+			fmt.Println("\n  Would copy: " + file + " → " + dst + "\n")
+			continue
+		}
+		dst, err := destination(file, *dest, len(files) > 1, true)
+		if err == nil {
+			err = copyPath(file, dst)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "\n  Copy failed: "+err.Error()+"\n")
+			os.Exit(exitFailed)
+		}
+		fmt.Println("\n  Copied: " + m.filepicker.Styles.Selected.Render(file) + " → " + dst + "\n")
+	}
+}
+
+// copyPath copies src to dst, recursively if src is a directory.
+func copyPath(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return filepicker.CopyDir(src, dst)
+	}
+	return filepicker.CopyFile(src, dst)
+}
+
+// destination returns the path to copy src to, given the destination from the
+// command line. If dest is an existing directory, src is copied into it under
+// its own name, renamed if that is taken. If dest doesn't exist, it is created
+// as a directory when it ends with a separator or when several files are
+// copied, and used as the new file name otherwise. Unless create is set, the
+// missing directories are not created, e.g. for a dry run.
+func destination(src, dest string, several, create bool) (string, error) {
+	isDir := strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(os.PathSeparator))
+	dest, err := filepicker.ExpandPath(dest)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(dest)
+	switch {
+	case err == nil && info.IsDir():
+		return filepicker.AvailablePath(filepath.Join(dest, filepath.Base(src))), nil
+	case err == nil && several:
+		return "", fmt.Errorf("%s is not a directory", dest)
+	case err != nil && !os.IsNotExist(err):
+		return "", err
+	case err != nil && (isDir || several):
+		if create {
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return "", err
+			}
+		}
+		return filepath.Join(dest, filepath.Base(src)), nil
+	}
+
+	// dest is a file name, so make sure it doesn't point at src itself, which
+	// would truncate it before it is read.
+	if srcInfo, err := os.Stat(src); err == nil && info != nil && os.SameFile(srcInfo, info) {
+		return "", fmt.Errorf("%s and %s are the same file", src, dest)
+	}
+	if create {
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return "", err
 		}
-		fmt.Println("\n  Copied: " + m.filepicker.Styles.Selected.Render(mm.selectedFile) + "\n")
 	}
+	return dest, nil
 }
 
-func _isAbsolutePath(path string) bool {
-	return strings.HasPrefix(path, "/") || strings.HasPrefix(path, "~")
+// parseArgs parses the command line like flag.Parse, but also takes flags
+// after the positional arguments, as in copyfile ~/src -types .go, and returns
+// the positional arguments. Everything after -- is positional.
+func parseArgs() []string {
+	flag.Parse()
+	var args []string
+	for rest := flag.Args(); len(rest) > 0; rest = flag.Args() {
+		// The remaining arguments are a suffix of os.Args, so the one before
+		// them tells whether parsing stopped at --.
+		if os.Args[len(os.Args)-len(rest)-1] == "--" {
+			return append(args, rest...)
+		}
+		args = append(args, rest[0])
+		_ = flag.CommandLine.Parse(rest[1:])
+	}
+	return args
 }
 
-func _truePath(path string) string {
-	if strings.HasPrefix(path, "~") {
-		path = strings.Replace(path, "~", os.Getenv("HOME"), 1)
-	} else if !strings.HasPrefix(path, "/") {
-		curr, _ := os.Getwd()
-		path = curr + "/" + path
-		path = strings.Replace(path, "//", "/", -1)
-		path, _ = filepath.Abs(path)
+// parseTypes turns the comma separated list of the -types flag into
+// AllowedTypes. Extensions get a leading dot if they are missing one, so "go"
+// becomes ".go", while glob patterns like "*_test.go" are kept as they are.
+func parseTypes(list string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if strings.ContainsAny(t, `/\`) {
+			return nil, fmt.Errorf("%q is not an extension", t)
+		}
+		if !strings.ContainsAny(t, "*?[") && !strings.HasPrefix(t, ".") {
+			t = "." + t
+		}
+		if t == "." {
+			return nil, fmt.Errorf("%q is not an extension", t)
+		}
+		types = append(types, t)
 	}
-	return path
+	return types, nil
 }
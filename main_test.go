@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests run the test binary itself as copyfile, feeding the picker the
+// keys they type on stdin.
+func TestMain(m *testing.M) {
+	if os.Getenv("COPYFILE_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCopyfile runs copyfile with args in dir and types keys into the picker
+// until it exits. The keys are sent over and over, since the picker drops the
+// ones that arrive before it has listed the directory. It returns the exit
+// code and what was printed to stdout.
+func runCopyfile(t *testing.T, dir, keys string, args ...string) (int, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "COPYFILE_RUN_MAIN=1")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-done:
+			require.NoError(t, ctx.Err(), "copyfile didn't exit")
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return exitErr.ExitCode(), stdout.String()
+			}
+			require.NoError(t, err)
+			return 0, stdout.String()
+		case <-tick.C:
+			if keys != "" {
+				// Writing fails once copyfile is done, which is fine.
+				_, _ = stdin.Write([]byte(keys))
+			}
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644))
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+
+	t.Run("copied", func(t *testing.T) {
+		dest := t.TempDir()
+		code, _ := runCopyfile(t, src, " ", "-dest", dest+string(filepath.Separator))
+		assert.Equal(t, 0, code)
+		content, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(content))
+	})
+	t.Run("printed", func(t *testing.T) {
+		code, stdout := runCopyfile(t, src, " ", "-print")
+		assert.Equal(t, 0, code)
+		assert.Equal(t, filepath.Join(src, "a.txt")+"\n", stdout)
+	})
+	t.Run("quit without selecting", func(t *testing.T) {
+		code, _ := runCopyfile(t, src, "q")
+		assert.Equal(t, exitNoSelection, code)
+	})
+	t.Run("copy failed", func(t *testing.T) {
+		// The destination can't be created below a file.
+		code, _ := runCopyfile(t, src, " ", "-dest", filepath.Join(blocker, "sub")+string(filepath.Separator))
+		assert.Equal(t, exitFailed, code)
+	})
+	t.Run("invalid flags", func(t *testing.T) {
+		code, _ := runCopyfile(t, src, "", "-types", "a/b")
+		assert.Equal(t, exitFailed, code)
+	})
+}